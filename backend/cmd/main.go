@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"sk-im-bot/internal/api"
 	"sk-im-bot/internal/bot"
@@ -11,12 +18,16 @@ import (
 	"sk-im-bot/pkg/utils"
 )
 
+// httpShutdownTimeout 机器人排空完成后，等待 HTTP 服务处理完进行中请求的最长时间
+const httpShutdownTimeout = 5 * time.Second
+
 func main() {
 	// 1. 加载配置文件
 	// 默认从 "config/config.yaml" 读取
 	// 1. 加载配置
-	// 留空以自动搜索项目根目录下的 .env 文件
-	cfg, err := config.LoadConfig("")
+	// 自动搜索项目根目录下的 .env 文件；CONFIG_FILE 可指定额外合并的 YAML 配置
+	// (例如在 adapters 列表中声明多个适配器实例)
+	cfg, err := config.LoadConfig(os.Getenv("CONFIG_FILE"))
 	if err != nil {
 		fmt.Printf("警告: 无法加载配置文件: %v。将尝试使用默认值或环境变量。\n", err)
 		// 如果加载失败且没有默认配置，进行初始化
@@ -51,10 +62,39 @@ func main() {
 	// 负责管理后台的 REST API 请求，如登录、统计信息获取等
 	r := api.InitRouter()
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
+	srv := &http.Server{Addr: addr, Handler: r}
 	utils.Logger.Info(fmt.Sprintf("Web 服务器正在启动，监听地址: %s", addr))
 
-	// 运行服务器，如果发生错误则记录致命日志并退出
-	if err := r.Run(addr); err != nil {
-		utils.Logger.Fatal(err.Error())
+	// 在独立协程中运行服务器，如果发生错误则记录致命日志并退出
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.Logger.Fatal(err.Error())
+		}
+	}()
+
+	// 8. 等待 SIGINT / SIGTERM 信号后执行优雅停机
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop()
+
+	timeout, err := time.ParseDuration(cfg.Server.ShutdownTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	utils.Logger.Info(fmt.Sprintf("收到停机信号，开始优雅停机 (最长等待 %s)", timeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// 先停止接收平台事件并排空消息队列，再关闭前端监控连接与 HTTP 服务，最后关闭数据库
+	bot.Manager.Shutdown(shutdownCtx)
+	api.WSHub.Stop()
+	// 排空阶段可能已耗尽 shutdownCtx，HTTP 服务使用独立的截止时间等待进行中的请求结束
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer httpCancel()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		utils.Logger.Error(fmt.Sprintf("HTTP 服务器关闭异常: %v", err))
 	}
+	model.CloseDB()
+	utils.Logger.Info("服务已安全退出")
 }
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"sk-im-bot/internal/bot"
+	"sk-im-bot/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// blacklistBody 新增/修改黑名单条目的请求体
+// 过期时间可以直接指定 expires_at，也可以通过 duration (e.g. 2h) 设置临时封禁
+type blacklistBody struct {
+	Platform   string     `json:"platform"`
+	TargetType string     `json:"target_type"`
+	TargetID   string     `json:"target_id"`
+	Reason     string     `json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Duration   string     `json:"duration"`
+}
+
+// apply 校验请求体并写入黑名单条目
+func (b *blacklistBody) apply(item *model.Blacklist) (string, bool) {
+	if b.TargetID == "" {
+		return "target_id 不能为空", false
+	}
+	if b.TargetType == "" {
+		b.TargetType = model.BlacklistUser
+	}
+	if b.TargetType != model.BlacklistUser && b.TargetType != model.BlacklistGroup {
+		return "target_type 仅支持 user 或 group", false
+	}
+
+	expiresAt := b.ExpiresAt
+	if b.Duration != "" {
+		d, err := time.ParseDuration(b.Duration)
+		if err != nil || d <= 0 {
+			return "duration 格式非法", false
+		}
+		t := time.Now().Add(d)
+		expiresAt = &t
+	}
+
+	item.Platform = b.Platform
+	item.TargetType = b.TargetType
+	item.TargetID = b.TargetID
+	item.Reason = b.Reason
+	item.ExpiresAt = expiresAt
+	return "", true
+}
+
+// reloadBlacklist 通知机器人管理器同步最新的黑名单
+func reloadBlacklist() {
+	if bot.Manager != nil {
+		bot.Manager.ReloadBlacklist()
+	}
+}
+
+// GetBlacklist 获取黑名单列表，传入 active=true 时仅返回仍然生效的条目
+func GetBlacklist(c *gin.Context) {
+	var list []model.Blacklist
+	query := model.DB.Order("created_at desc")
+	if c.Query("active") == "true" {
+		query = query.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+	if err := query.Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询黑名单失败"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// CreateBlacklist 新增一条黑名单记录
+func CreateBlacklist(c *gin.Context) {
+	var body blacklistBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的输入数据"})
+		return
+	}
+
+	var item model.Blacklist
+	if msg, ok := body.apply(&item); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := model.DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存黑名单失败"})
+		return
+	}
+
+	reloadBlacklist()
+	c.JSON(http.StatusOK, item)
+}
+
+// UpdateBlacklist 修改指定的黑名单记录 (如延长或解除临时封禁)
+func UpdateBlacklist(c *gin.Context) {
+	var item model.Blacklist
+	if err := model.DB.First(&item, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "黑名单记录不存在"})
+		return
+	}
+
+	var body blacklistBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的输入数据"})
+		return
+	}
+	if msg, ok := body.apply(&item); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := model.DB.Save(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存黑名单失败"})
+		return
+	}
+
+	reloadBlacklist()
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteBlacklist 删除指定的黑名单记录 (解除封禁)
+func DeleteBlacklist(c *gin.Context) {
+	result := model.DB.Delete(&model.Blacklist{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除黑名单失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "黑名单记录不存在"})
+		return
+	}
+
+	reloadBlacklist()
+	c.JSON(http.StatusOK, gin.H{"status": "已解除封禁"})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"sk-im-bot/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedbackMaxLimit 单次查询返回的评价条数上限
+const feedbackMaxLimit = 500
+
+// feedbackStats 按平台汇总的评价数量
+type feedbackStats struct {
+	Platform string `json:"platform"`
+	Up       int64  `json:"up"`
+	Down     int64  `json:"down"`
+}
+
+// GetFeedback 获取用户对回复的评价列表，支持按 rating (up/down)、platform、session_id 过滤，limit 默认 50
+func GetFeedback(c *gin.Context) {
+	query := model.DB.Order("updated_at desc")
+	switch c.Query("rating") {
+	case "":
+	case "up":
+		query = query.Where("rating = ?", model.FeedbackUp)
+	case "down":
+		query = query.Where("rating = ?", model.FeedbackDown)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating 仅支持 up 或 down"})
+		return
+	}
+	if platform := c.Query("platform"); platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+	if sessionID := c.Query("session_id"); sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 格式非法"})
+			return
+		}
+		limit = min(n, feedbackMaxLimit)
+	}
+
+	var list []model.Feedback
+	if err := query.Limit(limit).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询评价失败"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetFeedbackStats 获取评价汇总：总体与各平台的赞、踩数量
+func GetFeedbackStats(c *gin.Context) {
+	var platforms []feedbackStats
+	err := model.DB.Model(&model.Feedback{}).
+		Select("platform, SUM(CASE WHEN rating > 0 THEN 1 ELSE 0 END) AS up, SUM(CASE WHEN rating < 0 THEN 1 ELSE 0 END) AS down").
+		Group("platform").Order("platform").Scan(&platforms).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询评价统计失败"})
+		return
+	}
+
+	var up, down int64
+	for _, p := range platforms {
+		up += p.Up
+		down += p.Down
+	}
+	c.JSON(http.StatusOK, gin.H{"up": up, "down": down, "platforms": platforms})
+}
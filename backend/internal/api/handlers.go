@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"sk-im-bot/internal/bot"
 	"sk-im-bot/internal/config"
 	"sk-im-bot/internal/model"
 	"sk-im-bot/pkg/utils"
@@ -74,3 +75,12 @@ func GetSessions(c *gin.Context) {
 	model.DB.Find(&sessions)
 	c.JSON(http.StatusOK, sessions)
 }
+
+// GetStats 获取机器人运行指标 (运行时长、限流计数等)
+func GetStats(c *gin.Context) {
+	if bot.Manager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "机器人管理器尚未初始化"})
+		return
+	}
+	c.JSON(http.StatusOK, bot.Manager.Stats())
+}
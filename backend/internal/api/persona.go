@@ -0,0 +1,199 @@
+package api
+
+import (
+	"net/http"
+
+	"sk-im-bot/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// personaBody 新增/修改人设的请求体
+type personaBody struct {
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	SystemPrompt string  `json:"system_prompt"`
+	Model        string  `json:"model"`
+	Temperature  float32 `json:"temperature"`
+	TopP         float32 `json:"top_p"`
+	MaxTokens    int     `json:"max_tokens"`
+}
+
+// apply 校验请求体并写入人设
+func (b *personaBody) apply(p *model.Persona) (string, bool) {
+	if b.Name == "" {
+		return "name 不能为空", false
+	}
+	if b.Temperature < 0 || b.Temperature > 2 {
+		return "temperature 取值范围为 0-2", false
+	}
+	if b.TopP < 0 || b.TopP > 1 {
+		return "top_p 取值范围为 0-1", false
+	}
+	if b.MaxTokens < 0 {
+		return "max_tokens 不能为负数", false
+	}
+
+	p.Name = b.Name
+	p.Description = b.Description
+	p.SystemPrompt = b.SystemPrompt
+	p.Model = b.Model
+	p.Temperature = b.Temperature
+	p.TopP = b.TopP
+	p.MaxTokens = b.MaxTokens
+	return "", true
+}
+
+// GetPersonas 获取全部人设定义
+func GetPersonas(c *gin.Context) {
+	var personas []model.Persona
+	if err := model.DB.Order("name").Find(&personas).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询人设失败"})
+		return
+	}
+	c.JSON(http.StatusOK, personas)
+}
+
+// CreatePersona 新增一个人设
+func CreatePersona(c *gin.Context) {
+	var body personaBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的输入数据"})
+		return
+	}
+
+	var persona model.Persona
+	if msg, ok := body.apply(&persona); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := model.DB.Create(&persona).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存人设失败，名称可能已存在"})
+		return
+	}
+	c.JSON(http.StatusOK, persona)
+}
+
+// UpdatePersona 修改指定的人设
+func UpdatePersona(c *gin.Context) {
+	var persona model.Persona
+	if err := model.DB.First(&persona, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "人设不存在"})
+		return
+	}
+
+	var body personaBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的输入数据"})
+		return
+	}
+	if msg, ok := body.apply(&persona); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := model.DB.Save(&persona).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存人设失败，名称可能已存在"})
+		return
+	}
+	c.JSON(http.StatusOK, persona)
+}
+
+// DeletePersona 删除指定的人设及其全部绑定
+func DeletePersona(c *gin.Context) {
+	var persona model.Persona
+	if err := model.DB.First(&persona, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "人设不存在"})
+		return
+	}
+	if err := model.DB.Where("persona_id = ?", persona.ID).Delete(&model.PersonaBinding{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除人设绑定失败"})
+		return
+	}
+	if err := model.DB.Delete(&persona).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除人设失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "人设已删除"})
+}
+
+// GetPersonaBindings 获取全部人设分配关系
+func GetPersonaBindings(c *gin.Context) {
+	var bindings []model.PersonaBinding
+	if err := model.DB.Preload("Persona").Order("scope, platform, target_id").Find(&bindings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询人设绑定失败"})
+		return
+	}
+	c.JSON(http.StatusOK, bindings)
+}
+
+// SetPersonaBinding 将人设分配到全局、平台、群组或私聊，同一范围已有绑定时覆盖
+func SetPersonaBinding(c *gin.Context) {
+	var body struct {
+		Scope     string `json:"scope"`
+		Platform  string `json:"platform"`
+		TargetID  string `json:"target_id"`
+		PersonaID uint   `json:"persona_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的输入数据"})
+		return
+	}
+
+	// 按范围规整字段，避免出现无法命中的绑定
+	switch body.Scope {
+	case model.PersonaScopeGlobal:
+		body.Platform, body.TargetID = "", ""
+	case model.PersonaScopePlatform:
+		body.TargetID = ""
+		if body.Platform == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "platform 不能为空"})
+			return
+		}
+	case model.PersonaScopeGroup, model.PersonaScopePrivate:
+		if body.Platform == "" || body.TargetID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "platform 与 target_id 不能为空"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope 仅支持 global, platform, group, private"})
+		return
+	}
+
+	var persona model.Persona
+	if err := model.DB.First(&persona, body.PersonaID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "人设不存在"})
+		return
+	}
+
+	binding := model.PersonaBinding{
+		Scope:     body.Scope,
+		Platform:  body.Platform,
+		TargetID:  body.TargetID,
+		PersonaID: persona.ID,
+	}
+	err := model.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "platform"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"persona_id"}),
+	}).Create(&binding).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存人设绑定失败"})
+		return
+	}
+	binding.Persona = persona
+	c.JSON(http.StatusOK, binding)
+}
+
+// DeletePersonaBinding 解除指定的人设分配
+func DeletePersonaBinding(c *gin.Context) {
+	result := model.DB.Delete(&model.PersonaBinding{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除人设绑定失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "人设绑定不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "人设绑定已解除"})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"sk-im-bot/internal/bot"
 	"sk-im-bot/internal/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +23,13 @@ func InitRouter() *gin.Engine {
 	// WebSocket 实时监控连接接口
 	r.GET("/ws", WSHandler)
 
+	// 平台适配器的回调入口 (Webhook 等)，由各适配器自行校验请求来源
+	if bot.Manager != nil {
+		for path, handler := range bot.Manager.Endpoints() {
+			r.Any(path, gin.WrapH(handler))
+		}
+	}
+
 	// ---- 受保护路由 (需要 JWT 鉴权) ----
 
 	api := r.Group("/api")
@@ -34,6 +42,28 @@ func InitRouter() *gin.Engine {
 		// 获取消息历史及会话管理数据
 		api.GET("/messages", GetMessages)
 		api.GET("/sessions", GetSessions)
+
+		// 人设管理及分配 (全局 / 平台 / 群组 / 私聊)
+		api.GET("/personas", GetPersonas)
+		api.POST("/personas", CreatePersona)
+		api.PUT("/personas/:id", UpdatePersona)
+		api.DELETE("/personas/:id", DeletePersona)
+		api.GET("/personas/bindings", GetPersonaBindings)
+		api.POST("/personas/bindings", SetPersonaBinding)
+		api.DELETE("/personas/bindings/:id", DeletePersonaBinding)
+
+		// 机器人运行指标 (仪表盘)
+		api.GET("/stats", GetStats)
+
+		// 黑名单管理 (支持临时封禁)
+		api.GET("/blacklist", GetBlacklist)
+		api.POST("/blacklist", CreateBlacklist)
+		api.PUT("/blacklist/:id", UpdateBlacklist)
+		api.DELETE("/blacklist/:id", DeleteBlacklist)
+
+		// 用户对回复的评价 (Discord 回复按钮)
+		api.GET("/feedback", GetFeedback)
+		api.GET("/feedback/stats", GetFeedbackStats)
 	}
 
 	return r
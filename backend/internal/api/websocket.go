@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"sync"
 
 	"sk-im-bot/pkg/utils"
 
@@ -31,6 +32,10 @@ type Hub struct {
 
 	// 来自客户端的注销请求。
 	Unregister chan *Client
+
+	// 停机信号，关闭后 hub 断开所有客户端并退出主循环。
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
 // WSHub 是全局 WebSocket hub 实例
@@ -39,6 +44,7 @@ var WSHub = &Hub{
 	Register:   make(chan *Client),
 	Unregister: make(chan *Client),
 	Clients:    make(map[*Client]bool),
+	quit:       make(chan struct{}),
 }
 
 // Run 处理 hub 的主循环
@@ -63,10 +69,23 @@ func (h *Hub) Run() {
 					delete(h.Clients, client)
 				}
 			}
+		case <-h.quit:
+			// 关闭发送通道后 writePump 会向客户端发送关闭帧并断开连接
+			for client := range h.Clients {
+				close(client.Send)
+				delete(h.Clients, client)
+			}
+			utils.Logger.Info("WebSocket hub 已停止，所有客户端已断开")
+			return
 		}
 	}
 }
 
+// Stop 通知 hub 断开所有客户端并停止运行，可重复调用
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() { close(h.quit) })
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true // 开发环境下允许所有跨域请求
@@ -81,7 +100,12 @@ func WSHandler(c *gin.Context) {
 		return
 	}
 	client := &Client{Hub: WSHub, Conn: conn, Send: make(chan []byte, 256)}
-	client.Hub.Register <- client
+	select {
+	case client.Hub.Register <- client:
+	case <-client.Hub.quit:
+		conn.Close()
+		return
+	}
 
 	go client.writePump()
 	go client.readPump()
@@ -90,7 +114,10 @@ func WSHandler(c *gin.Context) {
 // readPump 将消息从 websocket 连接泵送到 hub。
 func (c *Client) readPump() {
 	defer func() {
-		c.Hub.Unregister <- c
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.quit:
+		}
 		c.Conn.Close()
 	}()
 	for {
@@ -136,5 +163,9 @@ func BroadcastEvent(event interface{}) {
 		utils.Logger.Error("序列化广播事件失败", zap.Error(err))
 		return
 	}
-	WSHub.Broadcast <- jsonBytes
+	// hub 停止后直接丢弃事件，避免阻塞调用方
+	select {
+	case WSHub.Broadcast <- jsonBytes:
+	case <-WSHub.quit:
+	}
 }
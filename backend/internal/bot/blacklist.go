@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// blacklistRefreshInterval 黑名单缓存定期与数据库同步的间隔
+const blacklistRefreshInterval = time.Minute
+
+// BlacklistCache 黑名单的内存缓存，避免每条消息都查询数据库
+type BlacklistCache struct {
+	mu      sync.RWMutex
+	entries map[string]model.Blacklist // 以 "平台|类型|目标ID" 为键的生效条目
+}
+
+// NewBlacklistCache 创建一个空的黑名单缓存
+func NewBlacklistCache() *BlacklistCache {
+	return &BlacklistCache{entries: make(map[string]model.Blacklist)}
+}
+
+// blacklistKey 生成缓存键，旧数据中为空的目标类型按用户处理
+func blacklistKey(platform, targetType, targetID string) string {
+	if targetType == "" {
+		targetType = model.BlacklistUser
+	}
+	return platform + "|" + targetType + "|" + targetID
+}
+
+// Reload 从数据库全量加载仍然生效的黑名单条目并替换缓存
+func (c *BlacklistCache) Reload() error {
+	var list []model.Blacklist
+	if err := model.DB.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&list).Error; err != nil {
+		return err
+	}
+
+	entries := buildBlacklistEntries(list)
+	c.mu.Lock()
+	c.entries = entries
+	c.mu.Unlock()
+	return nil
+}
+
+// buildBlacklistEntries 按缓存键整理黑名单条目
+// 同一目标存在多条记录时保留生效时间最长的一条，避免先到期的记录在缓存中遮盖仍然生效的封禁
+func buildBlacklistEntries(list []model.Blacklist) map[string]model.Blacklist {
+	entries := make(map[string]model.Blacklist, len(list))
+	for _, item := range list {
+		key := blacklistKey(item.Platform, item.TargetType, item.TargetID)
+		if existing, ok := entries[key]; ok && !outlasts(item, existing) {
+			continue
+		}
+		entries[key] = item
+	}
+	return entries
+}
+
+// outlasts 判断条目 a 的解封时间是否晚于 b，永久封禁晚于任何临时封禁
+func outlasts(a, b model.Blacklist) bool {
+	if b.ExpiresAt == nil {
+		return false
+	}
+	return a.ExpiresAt == nil || a.ExpiresAt.After(*b.ExpiresAt)
+}
+
+// Match 检查事件的发送者或所在群组是否被拉黑，命中时返回对应条目
+// 平台为空的条目对所有平台生效
+func (c *BlacklistCache) Match(event MessageEvent) (*model.Blacklist, bool) {
+	keys := []string{
+		blacklistKey(event.Platform, model.BlacklistUser, event.UserID),
+		blacklistKey("", model.BlacklistUser, event.UserID),
+	}
+	if event.IsGroup {
+		keys = append(keys,
+			blacklistKey(event.Platform, model.BlacklistGroup, event.PlatformID),
+			blacklistKey("", model.BlacklistGroup, event.PlatformID),
+		)
+	}
+
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, key := range keys {
+		if item, ok := c.entries[key]; ok && item.Active(now) {
+			return &item, true
+		}
+	}
+	return nil, false
+}
+
+// BlockedEvent 消息因命中黑名单被丢弃时推送到管理控制台的事件
+type BlockedEvent struct {
+	Type string // 事件类型，固定为 "blocked"
+	MessageEvent
+	BlacklistID uint   // 命中的黑名单条目 ID
+	Reason      string // 拉黑原因
+}
+
+// ReloadBlacklist 立即从数据库刷新黑名单缓存，供管理接口在增删改后调用
+func (m *BotManager) ReloadBlacklist() {
+	if err := m.blacklist.Reload(); err != nil {
+		utils.Logger.Error("黑名单缓存刷新失败", zap.Error(err))
+	}
+}
+
+// blacklistLoop 定期刷新黑名单缓存，使临时封禁到期及外部修改能及时生效
+// 首次加载由 Start 同步完成，此处只负责后续刷新
+func (m *BotManager) blacklistLoop() {
+	ticker := time.NewTicker(blacklistRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.ReloadBlacklist()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// isBlocked 检查事件是否命中黑名单，命中时推送拦截事件到管理控制台
+func (m *BotManager) isBlocked(event MessageEvent) bool {
+	item, ok := m.blacklist.Match(event)
+	if !ok {
+		return false
+	}
+
+	utils.Logger.Info("消息命中黑名单已丢弃",
+		zap.String("平台", event.Platform),
+		zap.String("用户", event.UserID),
+		zap.Uint("blacklist_id", item.ID))
+	if m.broadcastFunc != nil {
+		m.broadcastFunc(BlockedEvent{
+			Type:         "blocked",
+			MessageEvent: event,
+			BlacklistID:  item.ID,
+			Reason:       item.Reason,
+		})
+	}
+	return true
+}
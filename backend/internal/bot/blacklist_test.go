@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"sk-im-bot/internal/model"
+)
+
+func TestBlacklistDuplicateKeepsLongestBan(t *testing.T) {
+	now := time.Now()
+	soon, later := now.Add(time.Minute), now.Add(time.Hour)
+	tests := []struct {
+		name string
+		list []model.Blacklist
+		want uint
+	}{
+		{"永久封禁在前", []model.Blacklist{{ID: 1}, {ID: 2, ExpiresAt: &soon}}, 1},
+		{"永久封禁在后", []model.Blacklist{{ID: 1, ExpiresAt: &soon}, {ID: 2}}, 2},
+		{"较晚解封在前", []model.Blacklist{{ID: 1, ExpiresAt: &later}, {ID: 2, ExpiresAt: &soon}}, 1},
+		{"较晚解封在后", []model.Blacklist{{ID: 1, ExpiresAt: &soon}, {ID: 2, ExpiresAt: &later}}, 2},
+	}
+	event := MessageEvent{Platform: "qq", UserID: "42"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.list {
+				tt.list[i].Platform, tt.list[i].TargetType, tt.list[i].TargetID = "qq", model.BlacklistUser, "42"
+			}
+			c := &BlacklistCache{entries: buildBlacklistEntries(tt.list)}
+			item, ok := c.Match(event)
+			if !ok || item.ID != tt.want {
+				t.Fatalf("Match() = %+v, %v; want entry %d", item, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// Permission 指令的执行权限等级，数值越大权限越高
+type Permission int
+
+const (
+	PermEveryone   Permission = iota // 所有人
+	PermGroupAdmin                   // 群主/群管理员 (私聊中发送者视为拥有此权限)
+	PermOwner                        // 机器人主人 (由平台配置 owners 指定)
+)
+
+// String 返回权限等级的中文描述，用于帮助文本
+func (p Permission) String() string {
+	switch p {
+	case PermOwner:
+		return "主人"
+	case PermGroupAdmin:
+		return "管理员"
+	default:
+		return "所有人"
+	}
+}
+
+// CommandContext 指令执行时的上下文，包含触发事件与解析后的参数
+type CommandContext struct {
+	Manager *BotManager    // 所属管理器，用于访问 LLM、会话等资源
+	Event   MessageEvent   // 触发指令的原始消息事件
+	Session *model.Session // 事件所属会话 (数据库异常时可能为 nil)
+	Name    string         // 实际使用的指令名 (可能为别名)
+	Args    []string       // 按空白切分的参数列表，支持引号包裹含空格的参数
+	RawArgs string         // 指令名之后的原始参数文本
+	Perm    Permission     // 发送者拥有的权限等级
+}
+
+// Reply 向指令来源会话发送回复，指令回复不计入 LLM 对话上下文
+func (c *CommandContext) Reply(content string) {
+	if _, err := c.Manager.deliver(c.Event, content); err != nil {
+		utils.Logger.Error("指令回复发送失败", zap.String("平台", c.Event.Platform), zap.Error(err))
+	}
+}
+
+// Command 定义一条聊天指令
+type Command struct {
+	Name        string                          // 指令名 (不含前缀)
+	Aliases     []string                        // 指令别名
+	Usage       string                          // 参数用法说明，例如 "[模型名]"
+	Description string                          // 功能描述
+	Permission  Permission                      // 执行所需的最低权限
+	Handler     func(ctx *CommandContext) error // 指令处理函数，返回的错误会回复给发送者
+}
+
+// CommandRegistry 指令注册表，负责指令的注册、解析、鉴权与分发
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	prefix   string              // 指令前缀
+	commands map[string]*Command // 指令名及别名到指令的映射
+}
+
+// NewCommandRegistry 创建一个使用指定前缀的空指令注册表
+func NewCommandRegistry(prefix string) *CommandRegistry {
+	if prefix == "" {
+		prefix = "/"
+	}
+	return &CommandRegistry{
+		prefix:   prefix,
+		commands: make(map[string]*Command),
+	}
+}
+
+// Register 注册一条指令，同名指令或别名会被后注册者覆盖
+func (r *CommandRegistry) Register(cmd *Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[strings.ToLower(cmd.Name)] = cmd
+	for _, alias := range cmd.Aliases {
+		r.commands[strings.ToLower(alias)] = cmd
+	}
+}
+
+// Lookup 解析消息正文，若以已注册的指令开头则返回指令、指令名与原始参数
+func (r *CommandRegistry) Lookup(content string) (*Command, string, string, bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, r.prefix) {
+		return nil, "", "", false
+	}
+	body := strings.TrimPrefix(content, r.prefix)
+	name, rawArgs, _ := strings.Cut(body, " ")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	r.mu.RLock()
+	cmd, ok := r.commands[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, "", "", false
+	}
+	return cmd, name, strings.TrimSpace(rawArgs), true
+}
+
+// Format 将指令名与参数拼接为带前缀的指令文本，用于将交互式指令转换为普通指令消息
+func (r *CommandRegistry) Format(name, rawArgs string) string {
+	text := r.prefix + name
+	if rawArgs = strings.TrimSpace(rawArgs); rawArgs != "" {
+		text += " " + rawArgs
+	}
+	return text
+}
+
+// IsCommand 判断消息正文是否为已注册的指令
+func (r *CommandRegistry) IsCommand(content string) bool {
+	_, _, _, ok := r.Lookup(content)
+	return ok
+}
+
+// Dispatch 执行消息对应的指令，返回是否已作为指令处理
+func (r *CommandRegistry) Dispatch(m *BotManager, event MessageEvent, session *model.Session) bool {
+	cmd, name, rawArgs, ok := r.Lookup(event.Content)
+	if !ok {
+		return false
+	}
+
+	ctx := &CommandContext{
+		Manager: m,
+		Event:   event,
+		Session: session,
+		Name:    name,
+		Args:    parseArgs(rawArgs),
+		RawArgs: rawArgs,
+		Perm:    m.permissionOf(event),
+	}
+
+	if ctx.Perm < cmd.Permission {
+		ctx.Reply(fmt.Sprintf("权限不足：%s%s 需要 %s 权限", r.prefix, cmd.Name, cmd.Permission))
+		return true
+	}
+
+	utils.Logger.Info("执行聊天指令", zap.String("指令", cmd.Name), zap.String("用户", event.UserID))
+	if err := cmd.Handler(ctx); err != nil {
+		ctx.Reply(err.Error())
+	}
+	return true
+}
+
+// HelpText 根据发送者权限生成可用指令的帮助文本
+func (r *CommandRegistry) HelpText(perm Permission) string {
+	r.mu.RLock()
+	seen := make(map[*Command]bool)
+	var cmds []*Command
+	for _, cmd := range r.commands {
+		if !seen[cmd] && cmd.Permission <= perm {
+			seen[cmd] = true
+			cmds = append(cmds, cmd)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+
+	var b strings.Builder
+	b.WriteString("可用指令：")
+	for _, cmd := range cmds {
+		b.WriteString("\n")
+		b.WriteString(r.prefix + cmd.Name)
+		if cmd.Usage != "" {
+			b.WriteString(" " + cmd.Usage)
+		}
+		b.WriteString(" - " + cmd.Description)
+		if len(cmd.Aliases) > 0 {
+			b.WriteString(fmt.Sprintf(" (别名: %s)", strings.Join(cmd.Aliases, ", ")))
+		}
+	}
+	return b.String()
+}
+
+// parseArgs 按空白切分参数，支持使用双引号或单引号包裹含空格的参数
+func parseArgs(raw string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range raw {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// permissionOf 计算事件发送者的权限等级
+func (m *BotManager) permissionOf(event MessageEvent) Permission {
+	if inst := m.adapterFor(event.Adapter); inst != nil && inst.owners[event.UserID] {
+		return PermOwner
+	}
+	if event.IsAdmin || !event.IsGroup {
+		return PermGroupAdmin
+	}
+	return PermEveryone
+}
+
+// RegisterCommand 向管理器注册一条自定义聊天指令
+func (m *BotManager) RegisterCommand(cmd *Command) {
+	m.commands.Register(cmd)
+}
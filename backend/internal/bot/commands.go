@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"sk-im-bot/internal/model"
+)
+
+// errNoSession 会话不可用 (通常是数据库异常) 时指令返回的错误
+var errNoSession = errors.New("当前会话不可用，请稍后再试")
+
+// registerBuiltinCommands 注册系统内置的聊天指令
+func registerBuiltinCommands(r *CommandRegistry) {
+	r.Register(&Command{
+		Name:        "help",
+		Aliases:     []string{"帮助"},
+		Description: "查看可用指令",
+		Permission:  PermEveryone,
+		Handler: func(ctx *CommandContext) error {
+			ctx.Reply(r.HelpText(ctx.Perm))
+			return nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "ping",
+		Description: "检查机器人是否在线",
+		Permission:  PermEveryone,
+		Handler: func(ctx *CommandContext) error {
+			uptime := time.Since(ctx.Manager.startedAt).Truncate(time.Second)
+			ctx.Reply(fmt.Sprintf("pong! 已运行 %s", uptime))
+			return nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "reset",
+		Aliases:     []string{"重置"},
+		Description: "清空当前会话的对话记忆",
+		Permission:  PermEveryone,
+		Handler: func(ctx *CommandContext) error {
+			if ctx.Session == nil {
+				return errNoSession
+			}
+			if err := ctx.Manager.resetSession(ctx.Session); err != nil {
+				return fmt.Errorf("重置失败: %v", err)
+			}
+			ctx.Reply("对话记忆已清空，我们重新开始吧")
+			return nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "model",
+		Usage:       "[模型名|default]",
+		Description: "查看或切换当前会话使用的模型",
+		Permission:  PermOwner,
+		Handler: func(ctx *CommandContext) error {
+			if ctx.Session == nil {
+				return errNoSession
+			}
+			llmClient := ctx.Manager.llmClient
+			if len(ctx.Args) == 0 {
+				current := ctx.Session.Model
+				if current == "" {
+					current = llmClient.Model() + " (默认)"
+				}
+				reply := "当前模型: " + current
+				if models := llmClient.Models(); len(models) > 0 {
+					reply += "\n可选模型: " + strings.Join(models, ", ")
+				}
+				ctx.Reply(reply)
+				return nil
+			}
+
+			name := ctx.Args[0]
+			if name == "default" {
+				name = ""
+			}
+			if err := model.DB.Model(ctx.Session).Update("model", name).Error; err != nil {
+				return fmt.Errorf("切换模型失败: %v", err)
+			}
+			if name == "" {
+				ctx.Reply("已恢复默认模型: " + llmClient.Model())
+			} else {
+				ctx.Reply("已切换模型: " + name)
+			}
+			return nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "persona",
+		Aliases:     []string{"人设"},
+		Usage:       "[人设名|default]",
+		Description: "查看或切换当前聊天使用的人设",
+		Permission:  PermGroupAdmin,
+		Handler: func(ctx *CommandContext) error {
+			if len(ctx.Args) == 0 {
+				var personas []model.Persona
+				if err := model.DB.Order("name").Find(&personas).Error; err != nil {
+					return fmt.Errorf("查询人设失败: %v", err)
+				}
+				reply := "当前人设: 无"
+				if current := ctx.Manager.resolvePersona(ctx.Event); current != nil {
+					reply = "当前人设: " + current.Name
+				}
+				if len(personas) > 0 {
+					names := make([]string, 0, len(personas))
+					for _, p := range personas {
+						names = append(names, p.Name)
+					}
+					reply += "\n可选人设: " + strings.Join(names, ", ")
+				}
+				ctx.Reply(reply)
+				return nil
+			}
+
+			if ctx.Args[0] == "default" {
+				if err := bindChatPersona(ctx.Event, nil); err != nil {
+					return fmt.Errorf("切换人设失败: %v", err)
+				}
+				ctx.Reply("已恢复默认人设")
+				return nil
+			}
+
+			var persona model.Persona
+			if err := model.DB.Where("name = ?", ctx.Args[0]).First(&persona).Error; err != nil {
+				return fmt.Errorf("人设 %s 不存在", ctx.Args[0])
+			}
+			if err := bindChatPersona(ctx.Event, &persona); err != nil {
+				return fmt.Errorf("切换人设失败: %v", err)
+			}
+			ctx.Reply("已切换人设: " + persona.Name)
+			return nil
+		},
+	})
+}
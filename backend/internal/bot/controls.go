@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"strconv"
+
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// 回复控件支持的操作
+const (
+	ActionRegenerate = "regenerate" // 重新生成：针对同一条触发消息重新回答
+	ActionContinue   = "continue"   // 继续：接着被截断的回答往下写
+	ActionUpvote     = "upvote"     // 赞
+	ActionDownvote   = "downvote"   // 踩
+	ActionModel      = "model"      // 切换会话模型，Value 为模型名
+)
+
+// continuePrompt 用户点击"继续"时作为当前消息发送给 LLM 的提示
+const continuePrompt = "请接着你的上一条回答继续写下去，不要重复已经写过的内容。"
+
+// ReplyControls 附加在回复上的交互控件
+type ReplyControls struct {
+	Ref    string   // 控件关联记录 (model.ReplyControl) 的标识，随操作事件回传
+	Models []string // 可切换的模型列表，为空时不提供模型菜单
+}
+
+// ReplyAction 用户在回复控件上执行的操作
+type ReplyAction struct {
+	Name  string // 操作名，见 Action* 常量
+	Ref   string // 控件关联记录的标识
+	Value string // 选择菜单的取值 (如模型名)
+}
+
+// attachControls 为刚发出的回复附加交互控件 (仅支持 ControlsAdapter 的适配器)，控件附加在最后一段回复上
+func (m *BotManager) attachControls(event MessageEvent, session *model.Session, triggerID uint, recorded []model.Message) {
+	if session == nil || triggerID == 0 || len(recorded) == 0 {
+		return
+	}
+	adapter, ok := m.senderFor(event).(ControlsAdapter)
+	if !ok {
+		return
+	}
+	last := recorded[len(recorded)-1]
+	if last.PlatformMsgID == "" {
+		return
+	}
+
+	control := model.ReplyControl{SessionID: session.ID, TriggerID: triggerID, ReplyID: last.ID}
+	if err := model.DB.Create(&control).Error; err != nil {
+		utils.Logger.Error("回复控件记录保存失败", zap.Error(err))
+		return
+	}
+	controls := ReplyControls{Ref: strconv.FormatUint(uint64(control.ID), 10), Models: m.llmClient.Models()}
+	if err := adapter.AttachControls(event.PlatformID, last.PlatformMsgID, controls); err != nil {
+		utils.Logger.Warn("回复控件附加失败", zap.String("实例", event.Adapter), zap.Error(err))
+	}
+}
+
+// handleAction 处理回复控件上的操作，控件必须属于事件所在的会话
+func (m *BotManager) handleAction(event MessageEvent) {
+	action := event.Action
+	id, err := strconv.ParseUint(action.Ref, 10, 64)
+	if err != nil {
+		return
+	}
+	var control model.ReplyControl
+	var session model.Session
+	if err := model.DB.First(&control, id).Error; err != nil {
+		m.actionReply(event, "该回复已失效")
+		return
+	}
+	if err := model.DB.First(&session, control.SessionID).Error; err != nil ||
+		session.Platform != event.Platform || session.PlatformID != event.PlatformID {
+		m.actionReply(event, "该回复已失效")
+		return
+	}
+
+	utils.Logger.Info("处理回复控件操作", zap.String("操作", action.Name), zap.String("用户", event.UserID), zap.Uint("控件", control.ID))
+	switch action.Name {
+	case ActionUpvote:
+		m.recordFeedback(event, control, model.FeedbackUp)
+	case ActionDownvote:
+		m.recordFeedback(event, control, model.FeedbackDown)
+	case ActionRegenerate:
+		m.regenerate(event, &session, control)
+	case ActionContinue:
+		m.continueReply(event, &session, control)
+	case ActionModel:
+		m.switchModel(event, &session, action.Value)
+	}
+}
+
+// actionReply 向执行操作的用户发送回复 (Discord 中仅操作者可见)
+func (m *BotManager) actionReply(event MessageEvent, content string) {
+	if _, err := m.deliver(event, content); err != nil {
+		utils.Logger.Error("操作回复发送失败", zap.String("实例", event.Adapter), zap.Error(err))
+	}
+}
+
+// recordFeedback 记录用户对回复的评价，重复评价时覆盖之前的结果
+func (m *BotManager) recordFeedback(event MessageEvent, control model.ReplyControl, rating int) {
+	// 评价保存原回复的内容快照，原回复已被清理时无从记录
+	var reply model.Message
+	if err := model.DB.First(&reply, control.ReplyID).Error; err != nil {
+		m.actionReply(event, "原回复已被清理，无法评价")
+		return
+	}
+
+	feedback := model.Feedback{
+		MessageID: control.ReplyID,
+		SessionID: control.SessionID,
+		Platform:  event.Platform,
+		UserID:    event.UserID,
+		Username:  event.Username,
+		Rating:    rating,
+		Content:   reply.Content,
+	}
+	err := model.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "username", "updated_at"}),
+	}).Create(&feedback).Error
+	if err != nil {
+		utils.Logger.Error("回复评价保存失败", zap.Error(err))
+		m.actionReply(event, "评价保存失败，请稍后再试")
+		return
+	}
+	m.actionReply(event, "感谢你的反馈")
+}
+
+// replayEvent 基于控件操作事件构造以普通消息形式回复的事件 (不再经由交互响应发送)
+func replayEvent(event MessageEvent, content, messageID string) MessageEvent {
+	event.Action = nil
+	event.InteractionID = ""
+	event.Quote = nil
+	event.Content = content
+	event.MessageID = messageID
+	event.MsgType = MsgTypeText
+	return event
+}
+
+// regenerate 针对触发原回复的消息重新生成回答，上下文只包含该消息之前的历史
+func (m *BotManager) regenerate(event MessageEvent, session *model.Session, control model.ReplyControl) {
+	var trigger model.Message
+	if err := model.DB.First(&trigger, control.TriggerID).Error; err != nil {
+		m.actionReply(event, "原消息已被清理，无法重新生成")
+		return
+	}
+
+	// 限流计入点击按钮的用户，原提问者只用于还原上下文
+	if scope, ok := m.limiter.Allow(event); !ok {
+		m.onRateLimited(event, scope)
+		return
+	}
+	replay := replayEvent(event, trigger.Content, trigger.PlatformMsgID)
+	replay.UserID, replay.Username = trigger.SenderID, trigger.Sender
+	m.generateReply(replay, session, &trigger, trigger.ID, trigger.ID)
+}
+
+// continueReply 让 LLM 接着原回答继续生成，续写内容引用原回答的最后一段
+// 上下文截止于原回答 (含)，之后会话中的新消息不参与续写
+func (m *BotManager) continueReply(event MessageEvent, session *model.Session, control model.ReplyControl) {
+	var reply model.Message
+	if err := model.DB.First(&reply, control.ReplyID).Error; err != nil {
+		m.actionReply(event, "原回复已被清理，无法继续")
+		return
+	}
+
+	if scope, ok := m.limiter.Allow(event); !ok {
+		m.onRateLimited(event, scope)
+		return
+	}
+	replay := replayEvent(event, continuePrompt, reply.PlatformMsgID)
+	m.generateReply(replay, session, nil, control.TriggerID, continueBefore(control))
+}
+
+// continueBefore 返回续写时上下文的截止边界：包含被续写的回答 (分段回复的最后一段) 及之前的历史
+func continueBefore(control model.ReplyControl) uint {
+	return control.ReplyID + 1
+}
+
+// switchModel 切换会话使用的模型，权限要求与 /model 指令一致
+func (m *BotManager) switchModel(event MessageEvent, session *model.Session, name string) {
+	if m.permissionOf(event) < PermOwner {
+		m.actionReply(event, "权限不足：切换模型需要 "+PermOwner.String()+" 权限")
+		return
+	}
+	if name == "default" {
+		name = ""
+	}
+	if err := model.DB.Model(session).Update("model", name).Error; err != nil {
+		m.actionReply(event, "切换模型失败")
+		return
+	}
+	if name == "" {
+		m.actionReply(event, "已恢复默认模型: "+m.llmClient.Model())
+		return
+	}
+	m.actionReply(event, "已切换模型: "+name)
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"sk-im-bot/internal/config"
+	"sk-im-bot/internal/model"
+)
+
+// historyQuery 返回记录中加载会话历史的查询
+func historyQuery(t *testing.T, statements []string) string {
+	t.Helper()
+	for _, sql := range statements {
+		if strings.Contains(sql, `FROM "messages"`) && strings.Contains(sql, "created_at >=") {
+			return sql
+		}
+	}
+	t.Fatalf("未找到加载会话历史的查询: %v", statements)
+	return ""
+}
+
+func TestContinueContextEndsAtClickedReply(t *testing.T) {
+	recorder := useDryRunDB(t)
+	m := &BotManager{chatCfg: config.ChatConfig{ContextMessages: 20}}
+	session := &model.Session{ID: 1}
+
+	// 会话中依次为：提问 (4)、被续写的回答 (5)、之后的提问 (6) 与回答 (7)
+	control := model.ReplyControl{SessionID: 1, TriggerID: 4, ReplyID: 5}
+	event := MessageEvent{Platform: "discord", PlatformID: "c1", Content: continuePrompt, IsGroup: true}
+	m.buildContext(session, nil, continueBefore(control), event)
+
+	sql := historyQuery(t, recorder.statements)
+	if !strings.Contains(sql, "id < 6") {
+		t.Errorf("续写的上下文应截止于被续写的回答 (id < 6)，实际查询: %s", sql)
+	}
+}
+
+func TestReplyContextEndsBeforeCurrentMessage(t *testing.T) {
+	recorder := useDryRunDB(t)
+	m := &BotManager{chatCfg: config.ChatConfig{ContextMessages: 20}}
+	current := &model.Message{ID: 9, SessionID: 1, Content: "问题"}
+
+	m.buildContext(&model.Session{ID: 1}, current, current.ID, MessageEvent{Content: current.Content})
+
+	if sql := historyQuery(t, recorder.statements); !strings.Contains(sql, "id < 9") {
+		t.Errorf("上下文应只包含当前消息之前的历史，实际查询: %s", sql)
+	}
+}
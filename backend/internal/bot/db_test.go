@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sk-im-bot/internal/model"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder 记录 gorm 生成的 SQL (参数已内联)，用于在没有数据库的环境中断言查询条件
+type sqlRecorder struct {
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface      { return r }
+func (r *sqlRecorder) Info(context.Context, string, ...interface{})  {}
+func (r *sqlRecorder) Warn(context.Context, string, ...interface{})  {}
+func (r *sqlRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// useDryRunDB 将 model.DB 替换为只生成 SQL、不连接数据库的 Postgres 方言实例，测试结束后恢复
+func useDryRunDB(t *testing.T) *sqlRecorder {
+	t.Helper()
+	recorder := &sqlRecorder{}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 user=test dbname=test"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := model.DB
+	model.DB = db
+	t.Cleanup(func() { model.DB = previous })
+	return recorder
+}
@@ -1,6 +1,13 @@
 package bot
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
 	"sk-im-bot/internal/config"
 	"sk-im-bot/pkg/utils"
 
@@ -10,49 +17,145 @@ import (
 
 // DiscordBot 实现针对 Discord 实时的机器人适配器
 type DiscordBot struct {
-	cfg     config.DiscordConfig // Discord 专用配置 (Token, 频道限制等)
-	session *discordgo.Session   // discordgo 的长连接 Session 句柄
-	handler func(MessageEvent)   // 收到消息后的分发逻辑处理函数回调
+	name    string                   // 适配器实例名
+	cfg     config.DiscordConfig     // Discord 专用配置 (Token, 频道限制等)
+	session *discordgo.Session       // 第一个分片的 Session 句柄，用于调用 REST 接口
+	shards  []*discordgo.Session     // 本进程运行的各网关分片的长连接
+	handler func(MessageEvent) error // 收到消息后的分发逻辑处理函数回调
+
+	interactionsMu sync.Mutex
+	interactions   map[string]*discordInteraction // 等待回复的斜杠指令交互，按交互 ID 索引
+
+	threadChannels map[string]bool          // 启用线程模式的频道
+	guilds         map[string]*discordGuild // 按服务器 ID 的设置，为空时服务所有服务器
+}
+
+func init() {
+	RegisterAdapterType(AdapterType{Name: "discord", Section: "discord", Factory: newDiscordAdapter})
+}
+
+// newDiscordAdapter 解析 Discord 实例配置并创建适配器
+func newDiscordAdapter(name string, settings map[string]interface{}, handler func(MessageEvent) error) (BotAdapter, config.AdapterConfig, error) {
+	cfg := config.DiscordConfig{
+		AdapterConfig: config.AdapterConfig{
+			Trigger:   config.TriggerConfig{Mention: true},
+			MaxLength: discordMessageLimit,
+		},
+		SlashCommands: true,
+		Components:    true,
+		Threads:       config.DiscordThreadConfig{Archive: 1440},
+	}
+	if err := config.DecodeSection(settings, &cfg); err != nil {
+		return nil, cfg.AdapterConfig, err
+	}
+	return NewDiscordBot(name, cfg, handler), cfg.AdapterConfig, nil
 }
 
 // NewDiscordBot 创建一个新的 Discord 机器人适配器实例
-func NewDiscordBot(cfg config.DiscordConfig, handler func(MessageEvent)) *DiscordBot {
+func NewDiscordBot(name string, cfg config.DiscordConfig, handler func(MessageEvent) error) *DiscordBot {
 	return &DiscordBot{
-		cfg:     cfg,
-		handler: handler,
+		name:           name,
+		cfg:            cfg,
+		handler:        handler,
+		interactions:   make(map[string]*discordInteraction),
+		threadChannels: toSet(cfg.Threads.Channels),
+		guilds:         newDiscordGuilds(cfg),
 	}
 }
 
-// Start 建立 WebSocket 连接并开始监听 Discord 的 Gateway 事件
+// discordIdentifyInterval 同一批次的分片建立连接后，下一批次开始前的等待时间 (Discord 的身份验证速率限制)
+const discordIdentifyInterval = 5 * time.Second
+
+// Start 按分片配置建立 WebSocket 连接并开始监听 Discord 的 Gateway 事件
 func (d *DiscordBot) Start() error {
-	var err error
-	// 使用 Bot Token 初始化 Session，注意 Token 必须带有 "Bot " 前缀
-	d.session, err = discordgo.New("Bot " + d.cfg.Token)
+	d.session, d.shards = nil, nil
+	count, ids, concurrency, err := d.shardPlan()
 	if err != nil {
 		return err
 	}
 
-	// 注册消息创建事件处理钩子
-	d.session.AddHandler(d.onMessage)
+	for i, id := range ids {
+		// 每批最多同时建立 concurrency 个连接，批次之间等待身份验证间隔
+		if i > 0 && i%concurrency == 0 {
+			time.Sleep(discordIdentifyInterval)
+		}
+		if err := d.openShard(id, count); err != nil {
+			utils.Logger.Error("无法开启 Discord 连接", zap.Int("分片", id), zap.Error(err))
+			d.Stop()
+			return err
+		}
+	}
 
-	// 设置 Intent (意图)，确保机器人有权限读取公屏消息和私聊消息
-	d.session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentsMessageContent
+	utils.Logger.Info("Discord 机器人已上线并处于监听状态", zap.Int("分片总数", count), zap.Ints("本进程分片", ids))
+	return nil
+}
 
-	// 打开长连接
-	err = d.session.Open()
+// shardPlan 确定分片总数、本进程运行的分片 ID 与每批可同时建立的连接数
+// 分片总数配置为 0 时查询 Discord 建议的分片数
+func (d *DiscordBot) shardPlan() (count int, ids []int, concurrency int, err error) {
+	count, concurrency = d.cfg.Shards.Count, 1
+	if count <= 0 {
+		probe, err := discordgo.New("Bot " + d.cfg.Token)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		gateway, err := probe.GatewayBot()
+		if err != nil {
+			return 0, nil, 0, fmt.Errorf("查询建议分片数失败: %w", err)
+		}
+		count = max(gateway.Shards, 1)
+		concurrency = max(gateway.SessionStartLimit.MaxConcurrency, 1)
+	}
+
+	ids = d.cfg.Shards.IDs
+	if len(ids) == 0 {
+		for id := 0; id < count; id++ {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		if id < 0 || id >= count {
+			return 0, nil, 0, fmt.Errorf("分片 ID %d 超出分片总数 %d", id, count)
+		}
+	}
+	return count, ids, concurrency, nil
+}
+
+// openShard 建立指定分片的长连接，第一个分片同时作为调用 REST 接口的 Session
+func (d *DiscordBot) openShard(id, count int) error {
+	// 使用 Bot Token 初始化 Session，注意 Token 必须带有 "Bot " 前缀
+	session, err := discordgo.New("Bot " + d.cfg.Token)
 	if err != nil {
-		utils.Logger.Error("无法开启 Discord 连接", zap.Error(err))
 		return err
 	}
+	session.ShardID, session.ShardCount = id, count
 
-	utils.Logger.Info("Discord 机器人已上线并处于监听状态")
-	return nil
+	// 注册消息创建事件处理钩子
+	// 同步分发事件以保持消息到达顺序，回调只负责投递队列，不会阻塞网关
+	session.SyncEvents = true
+	session.AddHandler(d.onMessage)
+	if d.cfg.SlashCommands {
+		session.AddHandler(d.onReady)
+	}
+	if d.cfg.SlashCommands || d.cfg.Components {
+		session.AddHandler(d.onInteraction)
+	}
+
+	// 设置 Intent (意图)，确保机器人有权限读取公屏消息和私聊消息
+	// Guilds 意图使状态缓存持有服务器、身份组与频道 (含线程)，用于计算成员权限与识别机器人开启的线程
+	session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentsMessageContent
+
+	if d.session == nil {
+		d.session = session
+	}
+	d.shards = append(d.shards, session)
+	return session.Open()
 }
 
-// Stop 关闭与 Discord 的连接并释放资源
+// Stop 关闭所有分片与 Discord 的连接并释放资源
 func (d *DiscordBot) Stop() {
-	if d.session != nil {
-		d.session.Close()
+	for _, session := range d.shards {
+		session.Close()
 	}
 }
 
@@ -63,34 +166,458 @@ func (d *DiscordBot) onMessage(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
-	// 鉴权限制：如果配置了特定服务器 (GuildID)，则忽略不相关的消息
-	if d.cfg.GuildID != "" && m.GuildID != d.cfg.GuildID {
+	// 鉴权限制：如果配置了服务器白名单，则忽略不相关的消息
+	if !d.guildAllowed(m.GuildID) {
 		return
 	}
 
 	// 判断消息来源是否为群组
 	isGroup := m.GuildID != ""
 
+	// 检测机器人是否被 @，并从正文中移除提及标记 (<@id> 与昵称形式 <@!id>)
+	content := m.Content
+	mentioned := false
+	for _, user := range m.Mentions {
+		if user.ID == s.State.User.ID {
+			mentioned = true
+			break
+		}
+	}
+	if mentioned {
+		content = strings.NewReplacer("<@"+s.State.User.ID+">", "", "<@!"+s.State.User.ID+">", "").Replace(content)
+		content = strings.TrimSpace(content)
+	}
+
+	// 机器人开启的对话线程中的消息均视为对机器人发言
+	parentID := ""
+	if isGroup {
+		parentID = d.threadParent(s, m.ChannelID)
+	}
+	if parentID != "" {
+		mentioned = true
+	}
+
+	// 服务器的频道白名单与身份组限制 (线程按其父频道判断)
+	accessChannel := m.ChannelID
+	if parentID != "" {
+		accessChannel = parentID
+	}
+	if !d.memberAllowed(m.GuildID, accessChannel, m.Member) {
+		return
+	}
+
+	// 拥有管理员或管理消息权限的成员视为群管理员
+	isAdmin := isGroup && discordAuthorIsAdmin(s, m.Message)
+
+	// 附件以占位符附加在正文之后，元数据随事件下发
+	attachments := discordAttachments(m.Attachments)
+	content = withAttachments(content, attachments)
+
+	// 引用回复：Discord 随事件下发被引用的原消息 (原消息已删除时仅有引用 ID)
+	var quote *QuotedMessage
+	if ref := m.ReferencedMessage; ref != nil {
+		quote = &QuotedMessage{ID: ref.ID, Content: withAttachments(ref.Content, discordAttachments(ref.Attachments))}
+		if ref.Author != nil {
+			quote.UserID = ref.Author.ID
+			quote.Username = ref.Author.Username
+			quote.FromBot = ref.Author.ID == s.State.User.ID
+		}
+	} else if m.MessageReference != nil && m.MessageReference.MessageID != "" {
+		quote = &QuotedMessage{ID: m.MessageReference.MessageID}
+	}
+
 	// 将原始事件包装为统一的内部 MessageEvent 结构并投递给回调
 	d.handler(MessageEvent{
-		Platform:   "discord",
-		PlatformID: m.ChannelID,       // Discord 服务中以频道作为目标
-		UserID:     m.Author.ID,       // 发言者的唯一 ID
-		Username:   m.Author.Username, // 发言者的昵称
-		Content:    m.Content,         // 文本内容
-		MsgType:    MsgTypeText,
-		IsGroup:    isGroup,
+		Platform:    "discord",
+		Adapter:     d.name,
+		PlatformID:  m.ChannelID,       // Discord 服务中以频道作为目标
+		MessageID:   m.ID,              // 消息 ID，用于引用回复
+		UserID:      m.Author.ID,       // 发言者的唯一 ID
+		Username:    m.Author.Username, // 发言者的昵称
+		Content:     content,           // 文本内容 (含附件占位符)
+		MsgType:     attachmentsMsgType(attachments, MsgTypeText),
+		IsGroup:     isGroup,
+		IsMentioned: mentioned,
+		IsAdmin:     isAdmin,
+		Quote:       quote,
+		Attachments: attachments,
+		ParentID:    parentID,
+		GuildID:     m.GuildID,
+		GroupName:   discordChannelName(s, m.ChannelID),
 	})
 }
 
-// SendMessage 发送一段文本消息到指定的 Discord 频道
-func (d *DiscordBot) SendMessage(targetID string, content string, isGroup bool) error {
-	_, err := d.session.ChannelMessageSend(targetID, content)
-	return err
+// discordAuthorIsAdmin 判断消息发送者在所在频道是否拥有管理员或管理消息权限
+// 按状态缓存中的服务器、频道与消息携带的成员身份组计算，缓存缺失时通过接口查询
+func discordAuthorIsAdmin(s *discordgo.Session, m *discordgo.Message) bool {
+	perms, err := s.State.MessagePermissions(m)
+	if err != nil {
+		if perms, err = s.UserChannelPermissions(m.Author.ID, m.ChannelID); err != nil {
+			return false
+		}
+	}
+	return perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageMessages) != 0
 }
 
-// SendImage 发送一张图片到指定的 Discord 频道 (通过发送链接触发实时预览)
+// discordAttachments 将 Discord 消息附件转换为平台无关的附件元数据，按 MIME 类型区分图片、音频与视频
+func discordAttachments(list []*discordgo.MessageAttachment) []Attachment {
+	var attachments []Attachment
+	for _, a := range list {
+		attachment := Attachment{
+			Type:        AttachmentFile,
+			ID:          a.ID,
+			Name:        a.Filename,
+			URL:         a.URL,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			Width:       a.Width,
+			Height:      a.Height,
+		}
+		switch {
+		case strings.HasPrefix(a.ContentType, "image/"):
+			attachment.Type = AttachmentImage
+		case strings.HasPrefix(a.ContentType, "audio/"):
+			attachment.Type = AttachmentAudio
+		case strings.HasPrefix(a.ContentType, "video/"):
+			attachment.Type = AttachmentVideo
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments
+}
+
+// withAttachments 在正文之后追加附件占位符
+func withAttachments(content string, attachments []Attachment) string {
+	parts := make([]string, 0, len(attachments)+1)
+	if content != "" {
+		parts = append(parts, content)
+	}
+	for _, a := range attachments {
+		parts = append(parts, a.placeholder())
+	}
+	return strings.Join(parts, " ")
+}
+
+// discordChannelName 从状态缓存读取服务器频道的显示名称 (如 "#general")，私信或缓存未命中时返回空
+func discordChannelName(s *discordgo.Session, channelID string) string {
+	ch, err := s.State.Channel(channelID)
+	if err != nil || ch.GuildID == "" || ch.Name == "" {
+		return ""
+	}
+	return "#" + ch.Name
+}
+
+// SendMessage 发送一段文本消息到指定的 Discord 频道，返回消息 ID；启用卡片时带标题结构的文本渲染为嵌入卡片
+func (d *DiscordBot) SendMessage(targetID string, content string, isGroup bool) (string, error) {
+	if d.cfg.Embeds {
+		return d.SendRich(targetID, NewOutboundMessage(Text(content)), isGroup)
+	}
+	msg, err := d.session.ChannelMessageSendComplex(targetID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: discordAllowedMentions(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// SendImage 发送一张图片到指定的 Discord 频道：网络图片以嵌入卡片展示，本地路径与 base64:// 内容作为附件上传
 func (d *DiscordBot) SendImage(targetID string, imageUrl string, isGroup bool) error {
-	_, err := d.session.ChannelMessageSend(targetID, imageUrl)
+	seg, err := ImageSource(imageUrl)
+	if err != nil {
+		return err
+	}
+	_, err = d.SendRich(targetID, NewOutboundMessage(seg), isGroup)
 	return err
 }
+
+// discordMaxEmbeds Discord 单条消息最多携带的嵌入卡片数
+const discordMaxEmbeds = 10
+
+// discordEmbedColor 卡片未指定颜色时使用的侧边颜色
+const discordEmbedColor = 0x5865F2
+
+// SendRich 将富消息渲染为 Discord 消息发送：@ 渲染为提及语法，网络图片渲染为嵌入卡片，
+// 二进制图片与文件作为附件上传，网络文件以链接附在正文末尾，引用回复使用 MessageReference
+func (d *DiscordBot) SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error) {
+	sent, err := d.session.ChannelMessageSendComplex(targetID, d.render(targetID, msg))
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// render 渲染富消息，启用卡片时先将带标题结构的文本段转换为卡片段
+func (d *DiscordBot) render(targetID string, msg OutboundMessage) *discordgo.MessageSend {
+	if d.cfg.Embeds {
+		msg = embedSegments(msg)
+	}
+	return renderDiscordMessage(targetID, msg)
+}
+
+// discordAllowedMentions 所有发送与编辑使用的提醒限制：只允许显式构造的 @ 产生提醒，
+// 模型输出或用户文本中的 @everyone、<@id> 等不会生效
+func discordAllowedMentions() *discordgo.MessageAllowedMentions {
+	return &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}, RepliedUser: true}
+}
+
+// renderDiscordMessage 将富消息渲染为 Discord 的消息发送参数
+func renderDiscordMessage(targetID string, msg OutboundMessage) *discordgo.MessageSend {
+	send := &discordgo.MessageSend{AllowedMentions: discordAllowedMentions()}
+	var content strings.Builder
+	var links []string
+	for _, seg := range msg.Segments {
+		switch seg.Type {
+		case OutboundText:
+			content.WriteString(seg.Text)
+		case OutboundMention:
+			if seg.UserID == MentionAll {
+				content.WriteString("@everyone")
+				send.AllowedMentions.Parse = append(send.AllowedMentions.Parse, discordgo.AllowedMentionTypeEveryone)
+				continue
+			}
+			content.WriteString("<@" + seg.UserID + ">")
+			send.AllowedMentions.Users = append(send.AllowedMentions.Users, seg.UserID)
+		case OutboundImage:
+			if len(seg.Data) > 0 {
+				send.Files = append(send.Files, discordFile(seg))
+			} else if len(send.Embeds) < discordMaxEmbeds {
+				send.Embeds = append(send.Embeds, &discordgo.MessageEmbed{Image: &discordgo.MessageEmbedImage{URL: seg.URL}})
+			} else {
+				links = append(links, seg.URL)
+			}
+		case OutboundFile:
+			if len(seg.Data) > 0 {
+				send.Files = append(send.Files, discordFile(seg))
+			} else {
+				links = append(links, seg.URL)
+			}
+		case OutboundReply:
+			send.Reference = &discordgo.MessageReference{MessageID: seg.MessageID, ChannelID: targetID}
+		case OutboundEmbed:
+			if len(send.Embeds) < discordMaxEmbeds {
+				send.Embeds = append(send.Embeds, discordEmbed(seg.Embed))
+			} else {
+				content.WriteString(seg.Embed.Text())
+			}
+		}
+	}
+	for _, link := range links {
+		if content.Len() > 0 {
+			content.WriteString("\n")
+		}
+		content.WriteString(link)
+	}
+	send.Content = content.String()
+	return send
+}
+
+// discordEmbed 将平台无关的卡片转换为 Discord 嵌入卡片
+func discordEmbed(e *Embed) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{Title: e.Title, Description: e.Description, Color: e.Color}
+	if embed.Color == 0 {
+		embed.Color = discordEmbedColor
+	}
+	for _, f := range e.Fields {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: f.Name, Value: f.Value, Inline: f.Inline})
+	}
+	return embed
+}
+
+// discordFile 将二进制出站消息段转换为 Discord 附件
+func discordFile(seg OutboundSegment) *discordgo.File {
+	return &discordgo.File{
+		Name:        seg.fileName(),
+		ContentType: http.DetectContentType(seg.Data),
+		Reader:      bytes.NewReader(seg.Data),
+	}
+}
+
+// discordEditInterval 流式回复时两次编辑消息之间的最小间隔，避免触发 Discord 速率限制
+const discordEditInterval = time.Second
+
+// discordTypingInterval 输入状态提示的刷新间隔 (Discord 的输入状态约 10 秒后自动消失)
+const discordTypingInterval = 8 * time.Second
+
+// discordMessageLimit Discord 单条消息的最大字符数
+const discordMessageLimit = 2000
+
+// maxLength 返回单条消息的字符上限，不超过 Discord 的硬性限制
+func (d *DiscordBot) maxLength() int {
+	if d.cfg.MaxLength <= 0 || d.cfg.MaxLength > discordMessageLimit {
+		return discordMessageLimit
+	}
+	return d.cfg.MaxLength
+}
+
+// BeginStream 开启流式回复：先显示"正在输入"，收到文本后发送消息并持续编辑
+func (d *DiscordBot) BeginStream(targetID string, isGroup bool, lead []OutboundSegment) (ReplyStream, error) {
+	stream := &discordStream{
+		bot:        d,
+		channelID:  targetID,
+		lead:       lead,
+		leadLen:    runeLen(renderDiscordMessage(targetID, NewOutboundMessage(lead...)).Content),
+		limit:      d.maxLength(),
+		stopTyping: make(chan struct{}),
+	}
+	// 输入状态仅为展示效果，发送失败不影响回复
+	if err := d.session.ChannelTyping(targetID); err != nil {
+		utils.Logger.Warn("Discord 输入状态发送失败", zap.String("实例", d.name), zap.Error(err))
+	}
+	go stream.keepTyping()
+	return stream, nil
+}
+
+// discordStream 通过反复编辑同一条消息实现渐进式投递，超出长度上限时定稿并在新消息中续写
+type discordStream struct {
+	bot        *DiscordBot
+	channelID  string
+	lead       []OutboundSegment                 // 附加在首条消息上的引用回复或 @
+	prefix     string                            // 首条消息正文前由 lead 渲染出的内容 (如 @ 提及)，编辑时保留
+	mentions   *discordgo.MessageAllowedMentions // 首条消息允许的提醒，编辑时沿用
+	leadLen    int                               // lead 渲染到首条消息正文中的字符数，首段需为其预留长度
+	limit      int                               // 单条消息的字符上限
+	messageID  string                            // 正在编辑的消息 ID，尚未发送时为空
+	sent       string                            // 正在编辑的消息最近一次投递的文本
+	lastEdit   time.Time                         // 最近一次投递的时间
+	finalized  []SentMessage                     // 已定稿 (不再编辑) 的消息
+	stopTyping chan struct{}                     // 首次投递或结束后关闭，停止刷新输入状态
+	stopOnce   sync.Once
+}
+
+// keepTyping 在首条消息发出前周期性刷新"正在输入"状态
+func (s *discordStream) keepTyping() {
+	ticker := time.NewTicker(discordTypingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.bot.session.ChannelTyping(s.channelID)
+		case <-s.stopTyping:
+			return
+		}
+	}
+}
+
+// Update 定稿已经写满的分段，并按编辑间隔节流投递最后一段
+func (s *discordStream) Update(text string) error {
+	chunks := SplitMessageWithLead(text, s.limit, s.leadLen)
+	if len(chunks) == 0 {
+		return nil
+	}
+	for len(s.finalized) < len(chunks)-1 {
+		if err := s.commit(chunks[len(s.finalized)]); err != nil {
+			return err
+		}
+	}
+	if time.Since(s.lastEdit) < discordEditInterval {
+		return nil
+	}
+	return s.flush(chunks[len(chunks)-1])
+}
+
+// Finish 投递最终文本的全部分段，返回实际发出的各条消息；启用卡片时定稿的分段再编辑为嵌入卡片
+func (s *discordStream) Finish(text string) ([]SentMessage, error) {
+	defer s.stopOnce.Do(func() { close(s.stopTyping) })
+
+	chunks := SplitMessageWithLead(text, s.limit, s.leadLen)
+	for len(s.finalized) < len(chunks) {
+		if err := s.commit(chunks[len(s.finalized)]); err != nil {
+			return s.finalized, err
+		}
+	}
+	if s.bot.cfg.Embeds {
+		s.renderEmbeds()
+	}
+	return s.finalized, nil
+}
+
+// renderEmbeds 将带标题结构的已发分段编辑为嵌入卡片 (首条消息保留 lead 渲染出的 @ 等前缀)，编辑失败时保留文本
+func (s *discordStream) renderEmbeds() {
+	for i, msg := range s.finalized {
+		embed, ok := ParseEmbed(msg.Content)
+		if !ok {
+			continue
+		}
+		content := ""
+		if i == 0 {
+			content = strings.TrimSpace(s.prefix)
+		}
+		_, err := s.bot.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:              msg.ID,
+			Channel:         s.channelID,
+			Content:         &content,
+			Embeds:          []*discordgo.MessageEmbed{discordEmbed(embed)},
+			AllowedMentions: s.allowedMentions(i == 0),
+		})
+		if err != nil {
+			utils.Logger.Warn("Discord 回复渲染为卡片失败", zap.String("实例", s.bot.name), zap.Error(err))
+		}
+	}
+}
+
+// allowedMentions 编辑消息时的提醒限制，首条消息沿用发送时由 lead 放行的 @
+func (s *discordStream) allowedMentions(first bool) *discordgo.MessageAllowedMentions {
+	if first && s.mentions != nil {
+		return s.mentions
+	}
+	return discordAllowedMentions()
+}
+
+// commit 将当前消息定稿为指定文本，后续内容写入新消息
+func (s *discordStream) commit(chunk string) error {
+	if err := s.flush(chunk); err != nil {
+		return err
+	}
+	s.finalized = append(s.finalized, SentMessage{ID: s.messageID, Content: chunk})
+	s.messageID, s.sent = "", ""
+	return nil
+}
+
+// flush 当前消息尚未发送时发送新消息，否则编辑该消息
+func (s *discordStream) flush(text string) error {
+	if text == "" || text == s.sent {
+		return nil
+	}
+	s.stopOnce.Do(func() { close(s.stopTyping) })
+	s.lastEdit = time.Now()
+
+	first := len(s.finalized) == 0
+	switch {
+	case s.messageID == "" && first && len(s.lead) > 0:
+		send := renderDiscordMessage(s.channelID, NewOutboundMessage(append(append([]OutboundSegment{}, s.lead...), Text(text))...))
+		s.prefix = strings.TrimSuffix(send.Content, text)
+		s.mentions = send.AllowedMentions
+		msg, err := s.bot.session.ChannelMessageSendComplex(s.channelID, send)
+		if err != nil {
+			return err
+		}
+		s.messageID = msg.ID
+	case s.messageID == "":
+		msg, err := s.bot.session.ChannelMessageSendComplex(s.channelID, &discordgo.MessageSend{
+			Content:         text,
+			AllowedMentions: discordAllowedMentions(),
+		})
+		if err != nil {
+			return err
+		}
+		s.messageID = msg.ID
+	default:
+		edited := text
+		if first {
+			edited = s.prefix + text
+		}
+		_, err := s.bot.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:              s.messageID,
+			Channel:         s.channelID,
+			Content:         &edited,
+			AllowedMentions: s.allowedMentions(first),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.sent = text
+	return nil
+}
@@ -0,0 +1,330 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"sk-im-bot/pkg/utils"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// discordInteractionTTL 交互令牌的有效期 (Discord 规定为 15 分钟)，预留余量后清理未回复的交互
+const discordInteractionTTL = 14 * time.Minute
+
+// discordAskCommand 直接向 LLM 提问的斜杠指令名，其余指令交由聊天指令注册表处理
+const discordAskCommand = "ask"
+
+// discordSlashCommands 注册到 Discord 的斜杠指令定义
+var discordSlashCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:        discordAskCommand,
+		Description: "向机器人提问",
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "question",
+			Description: "问题内容",
+			Required:    true,
+		}},
+	},
+	{
+		Name:        "reset",
+		Description: "清空当前会话的对话记忆",
+	},
+	{
+		Name:        "persona",
+		Description: "查看或切换当前聊天使用的人设",
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "name",
+			Description: "人设名，default 表示恢复默认 (留空查看当前人设)",
+		}},
+	},
+	{
+		Name:        "model",
+		Description: "查看或切换当前会话使用的模型",
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "name",
+			Description: "模型名，default 表示恢复默认 (留空查看当前模型)",
+		}},
+	},
+}
+
+// onReady 连接就绪后注册斜杠指令：配置了 GuildID 时注册到该服务器 (即时生效)，否则全局注册
+// 注册为覆盖式，断线重连后重复注册不会产生重复指令；分片运行时只由第一个分片注册
+func (d *DiscordBot) onReady(s *discordgo.Session, r *discordgo.Ready) {
+	if s != d.session {
+		return
+	}
+	appID := r.User.ID
+	if r.Application != nil && r.Application.ID != "" {
+		appID = r.Application.ID
+	}
+	// 同步事件模式下在独立协程中调用 REST 接口，避免阻塞网关
+	go func() {
+		if _, err := s.ApplicationCommandBulkOverwrite(appID, d.cfg.GuildID, discordSlashCommands); err != nil {
+			utils.Logger.Error("Discord 斜杠指令注册失败", zap.String("实例", d.name), zap.Error(err))
+			return
+		}
+		utils.Logger.Info("Discord 斜杠指令已注册", zap.String("实例", d.name), zap.String("服务器", d.cfg.GuildID))
+	}()
+}
+
+// onInteraction 处理斜杠指令与回复控件：立即以延迟响应确认 (避免 3 秒超时)，随后将指令转换为消息事件交给管理器，
+// 回复经由 Interaction 返回的发送视图以交互响应及后续消息发出
+func (d *DiscordBot) onInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !d.guildAllowed(i.GuildID) {
+		return
+	}
+	if i.Type != discordgo.InteractionApplicationCommand && i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	// 服务器的频道白名单与身份组限制 (线程按其父频道判断)
+	parentID, accessChannel := "", i.ChannelID
+	if i.GuildID != "" {
+		if parentID = d.threadParent(s, i.ChannelID); parentID != "" {
+			accessChannel = parentID
+		}
+	}
+	if !d.memberAllowed(i.GuildID, accessChannel, i.Member) {
+		d.denyInteraction(s, i)
+		return
+	}
+
+	switch {
+	case i.Type == discordgo.InteractionApplicationCommand && d.cfg.SlashCommands:
+	case i.Type == discordgo.InteractionMessageComponent && d.cfg.Components:
+		d.onComponent(s, i, parentID)
+		return
+	default:
+		return
+	}
+
+	user, isAdmin := interactionUser(i)
+	if user == nil {
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		utils.Logger.Error("Discord 交互确认失败", zap.String("实例", d.name), zap.Error(err))
+		return
+	}
+	d.trackInteraction(i.Interaction, false)
+
+	data := i.ApplicationCommandData()
+	var args []string
+	for _, opt := range data.Options {
+		if opt.Type == discordgo.ApplicationCommandOptionString {
+			args = append(args, opt.StringValue())
+		}
+	}
+	event := MessageEvent{
+		Platform:      "discord",
+		Adapter:       d.name,
+		PlatformID:    i.ChannelID,
+		InteractionID: i.ID,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Content:       strings.Join(args, " "),
+		MsgType:       MsgTypeText,
+		IsGroup:       i.GuildID != "",
+		IsMentioned:   true,
+		IsAdmin:       isAdmin,
+		ParentID:      parentID,
+		GuildID:       i.GuildID,
+		GroupName:     discordChannelName(s, i.ChannelID),
+	}
+	if data.Name != discordAskCommand {
+		event.Command = data.Name
+	}
+	d.handler(event)
+}
+
+// denyInteraction 以仅操作者可见的消息拒绝无权使用机器人的交互
+func (d *DiscordBot) denyInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "你无权在此频道使用机器人",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		utils.Logger.Error("Discord 交互确认失败", zap.String("实例", d.name), zap.Error(err))
+	}
+}
+
+// interactionUser 返回触发交互的用户及其是否具有服务器管理权限 (私信中 Member 为空)
+func interactionUser(i *discordgo.InteractionCreate) (*discordgo.User, bool) {
+	if i.Member == nil {
+		return i.User, false
+	}
+	return i.Member.User, i.Member.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageMessages) != 0
+}
+
+// discordInteraction 一次等待回复的交互：首条回复填充延迟响应，之后的回复作为后续消息发送
+// 控件交互的延迟响应不占位，回复均以仅操作者可见的后续消息发送
+type discordInteraction struct {
+	interaction *discordgo.Interaction
+	component   bool // 是否为控件 (按钮、菜单) 交互
+	mu          sync.Mutex
+	responded   bool // 延迟响应是否已填充内容
+}
+
+// trackInteraction 记录等待回复的交互，斜杠指令到期时仍未回复则删除"正在思考"的占位响应
+func (d *DiscordBot) trackInteraction(interaction *discordgo.Interaction, component bool) {
+	pending := &discordInteraction{interaction: interaction, component: component, responded: component}
+	d.interactionsMu.Lock()
+	d.interactions[interaction.ID] = pending
+	d.interactionsMu.Unlock()
+
+	time.AfterFunc(discordInteractionTTL, func() {
+		d.interactionsMu.Lock()
+		delete(d.interactions, interaction.ID)
+		d.interactionsMu.Unlock()
+
+		pending.mu.Lock()
+		defer pending.mu.Unlock()
+		if !pending.responded {
+			d.session.InteractionResponseDelete(interaction)
+		}
+	})
+}
+
+// discordInteractionNotice 交互未产生任何回复时替换占位响应的提示
+const discordInteractionNotice = "本次请求未能完成，请稍后重试"
+
+// FinishInteraction 结束交互：延迟响应仍未填充内容时改为简短提示 (编辑失败则删除)，不再等待到期清理
+func (d *DiscordBot) FinishInteraction(id string) {
+	d.interactionsMu.Lock()
+	pending := d.interactions[id]
+	delete(d.interactions, id)
+	d.interactionsMu.Unlock()
+	if pending == nil {
+		return
+	}
+
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+	if pending.responded {
+		return
+	}
+	pending.responded = true
+	notice := discordInteractionNotice
+	_, err := d.session.InteractionResponseEdit(pending.interaction, &discordgo.WebhookEdit{
+		Content:         &notice,
+		AllowedMentions: discordAllowedMentions(),
+	})
+	if err != nil {
+		utils.Logger.Warn("Discord 交互占位响应更新失败", zap.String("实例", d.name), zap.Error(err))
+		d.session.InteractionResponseDelete(pending.interaction)
+	}
+}
+
+// Interaction 返回回复指定交互的发送视图
+func (d *DiscordBot) Interaction(id string) BotAdapter {
+	d.interactionsMu.Lock()
+	pending := d.interactions[id]
+	d.interactionsMu.Unlock()
+	return &discordInteractionSender{bot: d, pending: pending}
+}
+
+// discordInteractionSender 交互的发送视图，交互已过期 (pending 为 nil) 时退回普通频道消息
+type discordInteractionSender struct {
+	bot     *DiscordBot
+	pending *discordInteraction
+}
+
+// Start 发送视图没有独立的连接，无需启动
+func (s *discordInteractionSender) Start() error { return nil }
+
+// Stop 发送视图没有独立的连接，无需停止
+func (s *discordInteractionSender) Stop() {}
+
+// SendMessage 以交互响应发送文本消息
+func (s *discordInteractionSender) SendMessage(targetID string, content string, isGroup bool) (string, error) {
+	if s.bot.cfg.Embeds {
+		return s.SendRich(targetID, NewOutboundMessage(Text(content)), isGroup)
+	}
+	return s.send(targetID, &discordgo.MessageSend{Content: content, AllowedMentions: discordAllowedMentions()})
+}
+
+// SendImage 以交互响应发送图片 (本地路径与 base64:// 内容作为附件上传)
+func (s *discordInteractionSender) SendImage(targetID string, imageUrl string, isGroup bool) error {
+	seg, err := ImageSource(imageUrl)
+	if err != nil {
+		return err
+	}
+	_, err = s.SendRich(targetID, NewOutboundMessage(seg), isGroup)
+	return err
+}
+
+// SendRich 以交互响应发送富消息 (交互响应不支持引用回复，引用段被忽略)
+func (s *discordInteractionSender) SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error) {
+	return s.send(targetID, s.bot.render(targetID, msg))
+}
+
+// send 首条回复编辑延迟响应，其余回复作为后续消息发送
+func (s *discordInteractionSender) send(targetID string, msg *discordgo.MessageSend) (string, error) {
+	if s.pending == nil {
+		sent, err := s.bot.session.ChannelMessageSendComplex(targetID, msg)
+		if err != nil {
+			return "", err
+		}
+		return sent.ID, nil
+	}
+
+	s.pending.mu.Lock()
+	defer s.pending.mu.Unlock()
+	if !s.pending.responded {
+		edit := &discordgo.WebhookEdit{
+			Content:         &msg.Content,
+			Files:           msg.Files,
+			AllowedMentions: msg.AllowedMentions,
+		}
+		if len(msg.Embeds) > 0 {
+			edit.Embeds = &msg.Embeds
+		}
+		sent, err := s.bot.session.InteractionResponseEdit(s.pending.interaction, edit)
+		if err != nil {
+			return "", err
+		}
+		s.pending.responded = true
+		return sent.ID, nil
+	}
+
+	params := &discordgo.WebhookParams{
+		Content:         msg.Content,
+		Files:           msg.Files,
+		Embeds:          msg.Embeds,
+		AllowedMentions: msg.AllowedMentions,
+	}
+	if s.pending.component {
+		params.Flags = discordgo.MessageFlagsEphemeral
+	}
+	sent, err := s.bot.session.FollowupMessageCreate(s.pending.interaction, true, params)
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// AttachControls 交互已过期时直接编辑频道消息附加控件，否则通过交互的 Webhook 编辑响应消息
+func (s *discordInteractionSender) AttachControls(targetID, messageID string, controls ReplyControls) error {
+	if s.pending == nil {
+		return s.bot.AttachControls(targetID, messageID, controls)
+	}
+	if !s.bot.cfg.Components {
+		return nil
+	}
+	components := discordControls(controls)
+	_, err := s.bot.session.FollowupMessageEdit(s.pending.interaction, messageID, &discordgo.WebhookEdit{Components: &components})
+	return err
+}
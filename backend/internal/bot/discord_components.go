@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"strings"
+
+	"sk-im-bot/pkg/utils"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// discordComponentPrefix 回复控件 CustomID 的前缀，完整格式为 skbot:<操作>:<控件标识>
+const discordComponentPrefix = "skbot"
+
+// discordMaxSelectOptions Discord 选择菜单的选项数量上限
+const discordMaxSelectOptions = 25
+
+// discordComponentID 生成回复控件的 CustomID
+func discordComponentID(action, ref string) string {
+	return discordComponentPrefix + ":" + action + ":" + ref
+}
+
+// parseDiscordComponentID 解析回复控件的 CustomID，非本机器人生成的控件返回 false
+func parseDiscordComponentID(customID string) (action, ref string, ok bool) {
+	parts := strings.SplitN(customID, ":", 3)
+	if len(parts) != 3 || parts[0] != discordComponentPrefix || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// discordControls 将回复控件渲染为 Discord 消息组件：第一行为操作按钮，存在多个可选模型时第二行为模型菜单
+func discordControls(controls ReplyControls) []discordgo.MessageComponent {
+	rows := []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "重新生成", Style: discordgo.PrimaryButton, CustomID: discordComponentID(ActionRegenerate, controls.Ref)},
+			discordgo.Button{Label: "继续", Style: discordgo.SecondaryButton, CustomID: discordComponentID(ActionContinue, controls.Ref)},
+			discordgo.Button{Label: "👍", Style: discordgo.SecondaryButton, CustomID: discordComponentID(ActionUpvote, controls.Ref)},
+			discordgo.Button{Label: "👎", Style: discordgo.SecondaryButton, CustomID: discordComponentID(ActionDownvote, controls.Ref)},
+		}},
+	}
+	if len(controls.Models) < 2 {
+		return rows
+	}
+
+	options := []discordgo.SelectMenuOption{{Label: "默认模型", Value: "default"}}
+	for _, name := range controls.Models {
+		if len(options) >= discordMaxSelectOptions {
+			break
+		}
+		options = append(options, discordgo.SelectMenuOption{Label: name, Value: name})
+	}
+	return append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.SelectMenu{
+			MenuType:    discordgo.StringSelectMenu,
+			CustomID:    discordComponentID(ActionModel, controls.Ref),
+			Placeholder: "切换模型",
+			Options:     options,
+		},
+	}})
+}
+
+// AttachControls 编辑已发送的回复，附加操作按钮与模型菜单
+func (d *DiscordBot) AttachControls(targetID, messageID string, controls ReplyControls) error {
+	if !d.cfg.Components {
+		return nil
+	}
+	// 编辑消息时未携带的嵌入内容会被清空，先取回原消息保留其嵌入
+	msg, err := d.session.ChannelMessage(targetID, messageID)
+	if err != nil {
+		return err
+	}
+	_, err = d.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:              messageID,
+		Channel:         targetID,
+		Components:      discordControls(controls),
+		Embeds:          msg.Embeds,
+		AllowedMentions: discordAllowedMentions(),
+	})
+	return err
+}
+
+// onComponent 处理回复控件的点击：以延迟更新确认交互 (原消息保持不变)，操作结果以仅操作者可见的消息回复
+func (d *DiscordBot) onComponent(s *discordgo.Session, i *discordgo.InteractionCreate, parentID string) {
+	data := i.MessageComponentData()
+	name, ref, ok := parseDiscordComponentID(data.CustomID)
+	if !ok {
+		return
+	}
+	user, isAdmin := interactionUser(i)
+	if user == nil {
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		utils.Logger.Error("Discord 交互确认失败", zap.String("实例", d.name), zap.Error(err))
+		return
+	}
+	d.trackInteraction(i.Interaction, true)
+
+	action := &ReplyAction{Name: name, Ref: ref}
+	if len(data.Values) > 0 {
+		action.Value = data.Values[0]
+	}
+	d.handler(MessageEvent{
+		Platform:      "discord",
+		Adapter:       d.name,
+		PlatformID:    i.ChannelID,
+		InteractionID: i.ID,
+		UserID:        user.ID,
+		Username:      user.Username,
+		MsgType:       MsgTypeText,
+		IsGroup:       i.GuildID != "",
+		IsMentioned:   true,
+		IsAdmin:       isAdmin,
+		Action:        action,
+		ParentID:      parentID,
+		GuildID:       i.GuildID,
+	})
+}
@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"sk-im-bot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordGuild 单个服务器的访问限制与触发规则
+type discordGuild struct {
+	channels map[string]bool // 允许响应的频道，为空时不限
+	roles    map[string]bool // 允许使用机器人的身份组，为空时不限
+	trigger  *TriggerPolicy  // 覆盖的触发规则，为 nil 时沿用实例设置
+}
+
+// newDiscordGuilds 汇总按服务器的设置，单服务器模式的 guild_id 视为一个没有额外限制的服务器
+func newDiscordGuilds(cfg config.DiscordConfig) map[string]*discordGuild {
+	guilds := make(map[string]*discordGuild, len(cfg.Guilds)+1)
+	for id, g := range cfg.Guilds {
+		guild := &discordGuild{channels: toSet(g.Channels), roles: toSet(g.Roles)}
+		if g.Trigger != nil {
+			guild.trigger = NewTriggerPolicy(*g.Trigger)
+		}
+		guilds[id] = guild
+	}
+	if cfg.GuildID != "" && guilds[cfg.GuildID] == nil {
+		guilds[cfg.GuildID] = &discordGuild{}
+	}
+	return guilds
+}
+
+// guildAllowed 判断是否服务该服务器：未配置任何服务器时服务全部，私信 (guildID 为空) 不受服务器白名单限制
+func (d *DiscordBot) guildAllowed(guildID string) bool {
+	return guildID == "" || len(d.guilds) == 0 || d.guilds[guildID] != nil
+}
+
+// memberAllowed 判断成员能否在指定频道使用机器人：频道须在服务器的频道白名单中，
+// 配置了身份组限制时成员须拥有其中之一；未单独配置的服务器与私信不受限制
+func (d *DiscordBot) memberAllowed(guildID, channelID string, member *discordgo.Member) bool {
+	guild := d.guilds[guildID]
+	if guild == nil {
+		return true
+	}
+	if len(guild.channels) > 0 && !guild.channels[channelID] {
+		return false
+	}
+	if len(guild.roles) == 0 {
+		return true
+	}
+	if member == nil {
+		return false
+	}
+	for _, role := range member.Roles {
+		if guild.roles[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggerFor 返回消息所在服务器覆盖的触发规则，未覆盖时返回 nil
+func (d *DiscordBot) TriggerFor(event MessageEvent) *TriggerPolicy {
+	if guild := d.guilds[event.GuildID]; guild != nil {
+		return guild.trigger
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sk-im-bot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// newDiscordTestState 构造持有一个服务器 (含管理员身份组) 与一个频道的 Discord 状态缓存
+func newDiscordTestState(t *testing.T) *discordgo.Session {
+	t.Helper()
+	s := &discordgo.Session{State: discordgo.NewState()}
+	guild := &discordgo.Guild{
+		ID:      "g1",
+		OwnerID: "owner",
+		Roles: []*discordgo.Role{
+			{ID: "g1", Permissions: discordgo.PermissionSendMessages}, // @everyone
+			{ID: "admin", Permissions: discordgo.PermissionAdministrator},
+			{ID: "mod", Permissions: discordgo.PermissionManageMessages},
+		},
+	}
+	if err := s.State.GuildAdd(guild); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.State.ChannelAdd(&discordgo.Channel{ID: "c1", GuildID: "g1"}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDiscordChannelName(t *testing.T) {
+	s := newDiscordTestState(t)
+	if err := s.State.ChannelAdd(&discordgo.Channel{ID: "c2", GuildID: "g1", Name: "general"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"c2":      "#general",
+		"c1":      "", // 缓存中没有名称
+		"unknown": "", // 未缓存的频道或私信
+	}
+	for channelID, want := range tests {
+		if got := discordChannelName(s, channelID); got != want {
+			t.Errorf("discordChannelName(%q) = %q, want %q", channelID, got, want)
+		}
+	}
+}
+
+func TestDiscordAuthorIsAdmin(t *testing.T) {
+	s := newDiscordTestState(t)
+	tests := []struct {
+		name   string
+		author string
+		roles  []string
+		want   bool
+	}{
+		{"普通成员", "u1", nil, false},
+		{"管理员身份组", "u2", []string{"admin"}, true},
+		{"管理消息权限", "u3", []string{"mod"}, true},
+		{"服务器所有者", "owner", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &discordgo.Message{
+				ChannelID: "c1",
+				GuildID:   "g1",
+				Author:    &discordgo.User{ID: tt.author},
+				Member:    &discordgo.Member{Roles: tt.roles},
+			}
+			if got := discordAuthorIsAdmin(s, m); got != tt.want {
+				t.Errorf("discordAuthorIsAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// discordRequest 记录的一次 REST 请求
+type discordRequest struct {
+	method string
+	path   string
+	body   map[string]interface{}
+}
+
+// discordRecorder 替代 Discord REST 接口的传输层，记录请求并返回固定的消息；fail 返回 true 的请求以 403 应答
+type discordRecorder struct {
+	requests []discordRequest
+	fail     func(req discordRequest) bool
+}
+
+func (r *discordRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	record := discordRequest{method: req.Method, path: req.URL.Path}
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &record.body); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.requests = append(r.requests, record)
+	if r.fail != nil && r.fail(record) {
+		return &http.Response{
+			StatusCode: http.StatusForbidden,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"code":50013,"message":"Missing Permissions"}`)),
+			Request:    req,
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"id":"m1","channel_id":"c1"}`)),
+		Request:    req,
+	}, nil
+}
+
+// newDiscordTestBot 构造 REST 请求由 discordRecorder 接收的 Discord 实例
+func newDiscordTestBot(t *testing.T) (*DiscordBot, *discordRecorder) {
+	t.Helper()
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &discordRecorder{}
+	s.Client = &http.Client{Transport: recorder}
+	return &DiscordBot{name: "test", session: s, interactions: map[string]*discordInteraction{}}, recorder
+}
+
+// assertAllowedMentions 校验请求携带受限的提醒设置：parse 为空数组 (而非缺省或 null)，只放行指定用户
+func assertAllowedMentions(t *testing.T, req discordRequest, users ...string) {
+	t.Helper()
+	mentions, ok := req.body["allowed_mentions"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s %s 未携带 allowed_mentions: %v", req.method, req.path, req.body)
+	}
+	if parse, ok := mentions["parse"].([]interface{}); !ok || len(parse) != 0 {
+		t.Errorf("%s %s allowed_mentions.parse = %v, want []", req.method, req.path, mentions["parse"])
+	}
+	var got []string
+	if list, ok := mentions["users"].([]interface{}); ok {
+		for _, u := range list {
+			got = append(got, u.(string))
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(users, ",") {
+		t.Errorf("%s %s allowed_mentions.users = %v, want %v", req.method, req.path, got, users)
+	}
+}
+
+func TestDiscordSendMessageRestrictsMentions(t *testing.T) {
+	d, recorder := newDiscordTestBot(t)
+	if _, err := d.SendMessage("c1", "@everyone <@42>", true); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(recorder.requests))
+	}
+	assertAllowedMentions(t, recorder.requests[0])
+}
+
+func TestDiscordStreamRestrictsMentions(t *testing.T) {
+	d, recorder := newDiscordTestBot(t)
+	stream := &discordStream{
+		bot:        d,
+		channelID:  "c1",
+		lead:       []OutboundSegment{Mention("u1", "")},
+		limit:      discordMessageLimit,
+		stopTyping: make(chan struct{}),
+	}
+	if err := stream.flush("@everyone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.commit("@everyone <@42>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.flush("@here"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.flush("@here <@42>"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		method string
+		users  []string
+	}{
+		{http.MethodPost, []string{"u1"}},  // 首条消息放行 lead 中的 @
+		{http.MethodPatch, []string{"u1"}}, // 编辑首条消息沿用其提醒设置
+		{http.MethodPost, nil},
+		{http.MethodPatch, nil},
+	}
+	if len(recorder.requests) != len(want) {
+		t.Fatalf("requests = %d, want %d", len(recorder.requests), len(want))
+	}
+	for i, w := range want {
+		req := recorder.requests[i]
+		if req.method != w.method {
+			t.Errorf("request %d method = %s, want %s", i, req.method, w.method)
+		}
+		assertAllowedMentions(t, req, w.users...)
+	}
+}
+
+func TestDiscordInteractionSenderRestrictsMentions(t *testing.T) {
+	d, recorder := newDiscordTestBot(t)
+	pending := &discordInteraction{interaction: &discordgo.Interaction{AppID: "app", Token: "token"}}
+	sender := &discordInteractionSender{bot: d, pending: pending}
+	if _, err := sender.SendMessage("c1", "@everyone", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.SendMessage("c1", "<@42>", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(recorder.requests))
+	}
+	if req := recorder.requests[0]; req.method != http.MethodPatch || !strings.HasSuffix(req.path, "/messages/@original") {
+		t.Errorf("first request = %s %s, want edit of the deferred response", req.method, req.path)
+	}
+	for _, req := range recorder.requests {
+		assertAllowedMentions(t, req)
+	}
+}
+
+func TestDiscordFinishInteraction(t *testing.T) {
+	tests := []struct {
+		name      string
+		responded bool
+		requests  int
+	}{
+		{"未回复时替换占位响应", false, 1},
+		{"已回复时不再编辑", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, recorder := newDiscordTestBot(t)
+			interaction := &discordgo.Interaction{ID: "i1", AppID: "app", Token: "token"}
+			d.interactions["i1"] = &discordInteraction{interaction: interaction, responded: tt.responded}
+
+			d.FinishInteraction("i1")
+			d.FinishInteraction("i1") // 重复结束不会再次编辑
+
+			if len(recorder.requests) != tt.requests {
+				t.Fatalf("requests = %d, want %d", len(recorder.requests), tt.requests)
+			}
+			if tt.requests > 0 {
+				req := recorder.requests[0]
+				if req.method != http.MethodPatch || !strings.HasSuffix(req.path, "/messages/@original") {
+					t.Errorf("request = %s %s, want edit of the deferred response", req.method, req.path)
+				}
+				if req.body["content"] != discordInteractionNotice {
+					t.Errorf("content = %v, want %q", req.body["content"], discordInteractionNotice)
+				}
+			}
+			if _, ok := d.interactions["i1"]; ok {
+				t.Error("结束后交互仍在等待列表中")
+			}
+		})
+	}
+}
+
+func TestDiscordGuildAllowlistAllowsDirectMessages(t *testing.T) {
+	d, _ := newDiscordTestBot(t)
+	d.cfg.SlashCommands = true
+	d.guilds = newDiscordGuilds(config.DiscordConfig{Guilds: map[string]config.DiscordGuildConfig{"g1": {}}})
+	d.session.State.User = &discordgo.User{ID: "bot"}
+	var events []MessageEvent
+	d.handler = func(e MessageEvent) error { events = append(events, e); return nil }
+
+	for _, guildID := range []string{"", "g1", "g2"} {
+		events = nil
+		d.onMessage(d.session, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID: "m1", ChannelID: "c1", GuildID: guildID, Content: "hi", Author: &discordgo.User{ID: "u1"},
+		}})
+		messageAllowed := len(events) == 1
+
+		events = nil
+		interaction := &discordgo.Interaction{
+			ID: "i-" + guildID, AppID: "app", Token: "token", Type: discordgo.InteractionApplicationCommand,
+			ChannelID: "c1", GuildID: guildID, Data: discordgo.ApplicationCommandInteractionData{Name: discordAskCommand},
+		}
+		if guildID == "" {
+			interaction.User = &discordgo.User{ID: "u1"}
+		} else {
+			interaction.Member = &discordgo.Member{User: &discordgo.User{ID: "u1"}}
+		}
+		d.onInteraction(d.session, &discordgo.InteractionCreate{Interaction: interaction})
+		interactionAllowed := len(events) == 1
+
+		want := guildID != "g2"
+		if messageAllowed != want || interactionAllowed != want {
+			t.Errorf("guild %q: message allowed = %v, interaction allowed = %v, want %v", guildID, messageAllowed, interactionAllowed, want)
+		}
+	}
+}
+
+func TestDiscordBeginStreamIgnoresTypingFailure(t *testing.T) {
+	d, recorder := newDiscordTestBot(t)
+	observeLogs(t)
+	recorder.fail = func(req discordRequest) bool { return strings.HasSuffix(req.path, "/typing") }
+
+	stream, err := d.BeginStream("c1", true, nil)
+	if err != nil {
+		t.Fatalf("BeginStream() error = %v, want the stream despite the typing failure", err)
+	}
+	sent, err := stream.Finish("你好")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].Content != "你好" {
+		t.Errorf("sent = %+v, want one message", sent)
+	}
+}
+
+// assertContentWithin 校验每条发送或编辑的消息正文都不超过 limit 个字符
+func assertContentWithin(t *testing.T, requests []discordRequest, limit int) {
+	t.Helper()
+	for _, req := range requests {
+		content, _ := req.body["content"].(string)
+		if n := runeLen(content); n > limit {
+			t.Errorf("%s %s content has %d runes, want <= %d: %q", req.method, req.path, n, limit, content)
+		}
+	}
+}
+
+func TestDiscordStreamReservesLeadInFirstMessage(t *testing.T) {
+	d, recorder := newDiscordTestBot(t)
+	observeLogs(t)
+	d.cfg.MaxLength = 10
+	stream, err := d.BeginStream("c1", true, []OutboundSegment{Mention("42", "bob"), Text(" ")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 首段恰好等于上限，加上 "<@42> " 前缀后需要再次切分
+	sent, err := stream.Finish("一二三四五六七八九十")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, m := range sent {
+		contents = append(contents, m.Content)
+	}
+	if want := []string{"一二三四", "五六七八", "九十"}; !reflect.DeepEqual(contents, want) {
+		t.Errorf("sent = %q, want %q (首段为提及让出空间)", contents, want)
+	}
+	assertContentWithin(t, recorder.requests, 10)
+}
+
+func TestDeliverReservesLeadInFirstMessage(t *testing.T) {
+	d, recorder := newDiscordTestBot(t)
+	observeLogs(t)
+	m := &BotManager{adapters: map[string]*adapterInstance{"discord": {
+		name:      "discord",
+		adapter:   d,
+		maxLength: 10,
+		reply:     config.ReplyConfig{Mode: replyModeMention},
+	}}}
+	event := MessageEvent{Platform: "discord", Adapter: "discord", PlatformID: "c1", UserID: "42", Username: "bob", IsGroup: true}
+
+	sent, err := m.deliver(event, "一二三四五六七八九十")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, m := range sent {
+		contents = append(contents, m.Content)
+	}
+	if want := []string{"一二三四", "五六七八", "九十"}; !reflect.DeepEqual(contents, want) {
+		t.Errorf("sent = %q, want %q (首段为提及让出空间)", contents, want)
+	}
+	assertContentWithin(t, recorder.requests, 10)
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordArchiveDurations Discord 支持的线程自动归档时长 (分钟)，按升序排列
+var discordArchiveDurations = []int{60, 1440, 4320, 10080}
+
+// archiveDuration 返回不小于配置值的最短自动归档时长，超出上限时取最长时长
+func (d *DiscordBot) archiveDuration() int {
+	for _, minutes := range discordArchiveDurations {
+		if d.cfg.Threads.Archive <= minutes {
+			return minutes
+		}
+	}
+	return discordArchiveDurations[len(discordArchiveDurations)-1]
+}
+
+// OpenThread 消息位于启用线程模式的频道时开启对话线程：公开线程以触发消息为起点，
+// 私密线程无法挂在消息上，创建后将提问者加入线程；其余频道返回空
+func (d *DiscordBot) OpenThread(event MessageEvent, title string) (string, error) {
+	if !d.threadChannels[event.PlatformID] || event.MessageID == "" {
+		return "", nil
+	}
+
+	start := &discordgo.ThreadStart{Name: title, AutoArchiveDuration: d.archiveDuration()}
+	var thread *discordgo.Channel
+	var err error
+	if d.cfg.Threads.Private {
+		start.Type = discordgo.ChannelTypeGuildPrivateThread
+		if thread, err = d.session.ThreadStartComplex(event.PlatformID, start); err != nil {
+			return "", err
+		}
+		if err := d.session.ThreadMemberAdd(thread.ID, event.UserID); err != nil {
+			// 提问者无法加入的私密线程没有意义，删除后退回在原频道回复
+			d.session.ChannelDelete(thread.ID)
+			return "", err
+		}
+	} else if thread, err = d.session.MessageThreadStartComplex(event.PlatformID, event.MessageID, start); err != nil {
+		return "", err
+	}
+	// 写入状态缓存，使线程内的后续消息无需查询即可识别 (服务器所在的分片才能写入成功)
+	for _, shard := range d.shards {
+		shard.State.ChannelAdd(thread)
+	}
+	return thread.ID, nil
+}
+
+// RenameThread 修改对话线程的标题
+func (d *DiscordBot) RenameThread(threadID, title string) error {
+	_, err := d.session.ChannelEdit(threadID, &discordgo.ChannelEdit{Name: title})
+	return err
+}
+
+// threadParent 频道是机器人在线程模式频道中开启的线程时返回其父频道 ID，否则返回空
+// 优先查询事件所在分片的状态缓存，缓存中没有时 (如重启前开启的线程) 通过接口查询并写入缓存
+func (d *DiscordBot) threadParent(s *discordgo.Session, channelID string) string {
+	if len(d.threadChannels) == 0 || d.threadChannels[channelID] {
+		return ""
+	}
+	ch, err := s.State.Channel(channelID)
+	if err != nil {
+		if ch, err = s.Channel(channelID); err != nil {
+			return ""
+		}
+		s.State.ChannelAdd(ch)
+	}
+	if !ch.IsThread() || ch.OwnerID != s.State.User.ID || !d.threadChannels[ch.ParentID] {
+		return ""
+	}
+	return ch.ParentID
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// 卡片各部分的长度上限 (与 Discord 嵌入卡片的限制一致)，超出时不渲染为卡片
+const (
+	embedTitleLimit       = 256
+	embedDescriptionLimit = 4096
+	embedFieldNameLimit   = 256
+	embedFieldValueLimit  = 1024
+	embedMaxFields        = 25
+	embedTotalLimit       = 6000
+)
+
+// Embed 平台无关的结构化卡片，支持卡片的平台 (如 Discord) 渲染为原生卡片，其余平台渲染为文本
+// 描述与字段值中的 Markdown (代码块等) 原样保留
+type Embed struct {
+	Title       string
+	Description string
+	Fields      []EmbedField
+	Color       int // 卡片侧边颜色 (0xRRGGBB)，为 0 时使用平台默认颜色
+}
+
+// EmbedField 卡片中的一个字段
+type EmbedField struct {
+	Name   string
+	Value  string
+	Inline bool // 是否与相邻字段并排展示
+}
+
+// Text 返回卡片的纯文本形式：标题、描述与各字段依次成段
+func (e *Embed) Text() string {
+	var parts []string
+	if e.Title != "" {
+		parts = append(parts, e.Title)
+	}
+	if e.Description != "" {
+		parts = append(parts, e.Description)
+	}
+	for _, f := range e.Fields {
+		parts = append(parts, f.Name+"\n"+f.Value)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// ParseEmbed 将带有 Markdown 标题结构的回答解析为卡片：首行的一级标题作为卡片标题，
+// 二、三级标题各自开启一个字段，其余内容 (含代码块) 作为描述或字段值
+// 回答不含标题结构或超出卡片长度限制时返回 false，调用方应按普通文本发送
+func ParseEmbed(text string) (*Embed, bool) {
+	embed := &Embed{}
+	var body strings.Builder
+	var field *EmbedField
+	inCode := false
+	flush := func() {
+		value := strings.TrimSpace(body.String())
+		body.Reset()
+		if field == nil {
+			embed.Description = value
+			return
+		}
+		field.Value = value
+		embed.Fields = append(embed.Fields, *field)
+	}
+
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		if !inCode {
+			switch {
+			case i == 0 && strings.HasPrefix(trimmed, "# "):
+				embed.Title = strings.TrimSpace(strings.TrimPrefix(trimmed, "# "))
+				continue
+			case strings.HasPrefix(trimmed, "## "), strings.HasPrefix(trimmed, "### "):
+				flush()
+				field = &EmbedField{Name: strings.TrimSpace(strings.TrimLeft(trimmed, "#"))}
+				continue
+			}
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+
+	if embed.Title == "" && len(embed.Fields) == 0 {
+		return nil, false
+	}
+	return embed, embed.fits()
+}
+
+// fits 检查卡片是否满足各部分的长度限制，字段名与字段值不能为空
+func (e *Embed) fits() bool {
+	count := utf8.RuneCountInString
+	total := count(e.Title) + count(e.Description)
+	if count(e.Title) > embedTitleLimit || count(e.Description) > embedDescriptionLimit || len(e.Fields) > embedMaxFields {
+		return false
+	}
+	for _, f := range e.Fields {
+		if f.Name == "" || f.Value == "" || count(f.Name) > embedFieldNameLimit || count(f.Value) > embedFieldValueLimit {
+			return false
+		}
+		total += count(f.Name) + count(f.Value)
+	}
+	return total <= embedTotalLimit
+}
+
+// embedSegments 将可解析为卡片的文本段替换为卡片段，其余消息段保持不变
+func embedSegments(msg OutboundMessage) OutboundMessage {
+	segments := make([]OutboundSegment, 0, len(msg.Segments))
+	for _, seg := range msg.Segments {
+		if seg.Type == OutboundText {
+			if embed, ok := ParseEmbed(seg.Text); ok {
+				segments = append(segments, EmbedCard(*embed))
+				continue
+			}
+		}
+		segments = append(segments, seg)
+	}
+	return OutboundMessage{Segments: segments}
+}
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"strings"
+	"unicode"
+)
+
+// codeFence Markdown 代码块围栏标记
+const codeFence = "```"
+
+// textBlock 切分前的文本块：普通段落或完整的代码块
+type textBlock struct {
+	text   string // 块内容 (代码块不含首尾围栏)
+	code   bool   // 是否为代码块
+	header string // 代码块的起始围栏行，例如 "```go"
+}
+
+// SplitMessage 将长文本按平台单条消息的字符数上限切分为多段
+// 优先在段落 (空行) 与代码块边界处切分；超长的代码块按行拆开并在每段补齐围栏，
+// 保证每一段中的代码块都是闭合的；limit <= 0 时不做切分
+func SplitMessage(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if limit <= 0 || runeLen(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	current := ""
+	for _, block := range splitBlocks(text) {
+		for _, piece := range fitBlock(block, limit) {
+			switch {
+			case current == "":
+				current = piece
+			case runeLen(current)+2+runeLen(piece) <= limit:
+				current += "\n\n" + piece
+			default:
+				chunks = append(chunks, current)
+				current = piece
+			}
+		}
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// SplitMessageWithLead 与 SplitMessage 相同，但首段之前还会附加 leadLen 个字符的前缀 (如 @提问者)：
+// 首段加上前缀超出上限时按预留后的长度再次切分，保证每条实际发出的消息都不超过 limit
+func SplitMessageWithLead(text string, limit, leadLen int) []string {
+	chunks := SplitMessage(text, limit)
+	if limit <= 0 || leadLen <= 0 || len(chunks) == 0 || runeLen(chunks[0])+leadLen <= limit {
+		return chunks
+	}
+	first := SplitMessage(chunks[0], max(limit-leadLen, 1))
+	return append(first, chunks[1:]...)
+}
+
+// splitBlocks 将文本解析为段落与代码块，未闭合的代码块一直延续到文本末尾
+func splitBlocks(text string) []textBlock {
+	var blocks []textBlock
+	var lines []string
+	var code *textBlock
+
+	flushParagraph := func() {
+		if p := strings.TrimSpace(strings.Join(lines, "\n")); p != "" {
+			blocks = append(blocks, textBlock{text: p})
+		}
+		lines = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		isFence := strings.HasPrefix(strings.TrimSpace(line), codeFence)
+		switch {
+		case code != nil && isFence:
+			code.text = strings.Join(lines, "\n")
+			blocks = append(blocks, *code)
+			code, lines = nil, nil
+		case code != nil:
+			lines = append(lines, line)
+		case isFence:
+			flushParagraph()
+			code = &textBlock{code: true, header: strings.TrimSpace(line)}
+		case strings.TrimSpace(line) == "":
+			flushParagraph()
+		default:
+			lines = append(lines, line)
+		}
+	}
+
+	if code != nil {
+		code.text = strings.Join(lines, "\n")
+		blocks = append(blocks, *code)
+	} else {
+		flushParagraph()
+	}
+	return blocks
+}
+
+// fitBlock 将单个文本块渲染并拆分为不超过 limit 的若干片段
+func fitBlock(block textBlock, limit int) []string {
+	if !block.code {
+		return splitLines(block.text, limit)
+	}
+
+	wrap := func(body string) string { return block.header + "\n" + body + "\n" + codeFence }
+	if whole := wrap(block.text); runeLen(whole) <= limit {
+		return []string{whole}
+	}
+
+	// 围栏自身占用的长度，剩余空间用于代码行
+	room := limit - runeLen(wrap(""))
+	if room < 1 {
+		// 限制过小无法容纳围栏时退化为纯文本切分
+		return splitLines(block.text, limit)
+	}
+	var pieces []string
+	for _, body := range splitLines(block.text, room) {
+		pieces = append(pieces, wrap(body))
+	}
+	return pieces
+}
+
+// splitLines 按行合并文本，使每段不超过 limit；单行超长时再按字符切分
+func splitLines(text string, limit int) []string {
+	if runeLen(text) <= limit {
+		return []string{text}
+	}
+
+	var pieces []string
+	current := ""
+	for _, line := range strings.Split(text, "\n") {
+		for _, part := range hardSplit(line, limit) {
+			switch {
+			case current == "":
+				current = part
+			case runeLen(current)+1+runeLen(part) <= limit:
+				current += "\n" + part
+			default:
+				pieces = append(pieces, current)
+				current = part
+			}
+		}
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// hardSplit 将超长的单行按字符数切分，尽量在空白或标点之后断开
+func hardSplit(line string, limit int) []string {
+	runes := []rune(line)
+	if len(runes) <= limit {
+		return []string{line}
+	}
+
+	var parts []string
+	for len(runes) > limit {
+		cut := limit
+		// 在后 1/4 范围内寻找更自然的断点
+		for i := limit; i > limit*3/4; i-- {
+			if unicode.IsSpace(runes[i-1]) || unicode.IsPunct(runes[i-1]) {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// chunkDelay 根据片段长度模拟真人打字节奏，返回发送下一段之前应等待的毫秒范围
+func chunkDelay(chunk string) (int, int) {
+	base := runeLen(chunk) * 20
+	if base < 800 {
+		base = 800
+	}
+	if base > 3000 {
+		base = 3000
+	}
+	return base, base + 500
+}
+
+// runeLen 返回文本的字符数 (而非字节数)
+func runeLen(s string) int {
+	return len([]rune(s))
+}
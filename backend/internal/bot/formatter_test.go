@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"空文本", "  \n ", 10, nil},
+		{"不限长度", "一二三四五六", 0, []string{"一二三四五六"}},
+		{"恰好等于上限 (按字符而非字节)", "你好世界", 4, []string{"你好世界"}},
+		{"超出上限一个字符", "你好世界啊", 4, []string{"你好世界", "啊"}},
+		{"代理对字符不被拆开", "😀😀😀", 2, []string{"😀😀", "😀"}},
+		{"在标点后断开", "一二三四，五六七八", 6, []string{"一二三四，", "五六七八"}},
+		{"段落恰好合并到上限", "第一段\n\n第二段", 8, []string{"第一段\n\n第二段"}},
+		{"段落超出上限时分开", "第一段\n\n第二段", 7, []string{"第一段", "第二段"}},
+		{"完整代码块保持在一段", "说明\n\n```go\nfmt.Println(1)\n```", 30, []string{"说明\n\n```go\nfmt.Println(1)\n```"}},
+		{"超长代码块逐段补齐围栏", "```go\na\nb\nc\n```", 12, []string{"```go\na\n```", "```go\nb\n```", "```go\nc\n```"}},
+		{"未闭合的代码块补齐围栏", "```\n第一行\n第二行\n第三行", 11, []string{"```\n第一行\n```", "```\n第二行\n```", "```\n第三行\n```"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessage(tt.text, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitMessageInvariants 校验任意切分结果：不超过上限、不产生非法 UTF-8、代码块围栏成对且内容不丢失
+func TestSplitMessageInvariants(t *testing.T) {
+	code := "```python\n" + strings.Repeat("print('你好，世界') # 😀\n", 30) + "```"
+	text := strings.Repeat("这是一段很长的中文说明，包含 emoji 😀 与 English words. ", 20) + "\n\n" + code + "\n\n结尾。"
+	for _, limit := range []int{20, 50, 100, 2000} {
+		chunks := SplitMessage(text, limit)
+		if len(chunks) == 0 {
+			t.Fatalf("limit %d: no chunks", limit)
+		}
+		var lines int
+		for i, chunk := range chunks {
+			if n := utf8.RuneCountInString(chunk); n > limit {
+				t.Errorf("limit %d: chunk %d has %d runes", limit, i, n)
+			}
+			if !utf8.ValidString(chunk) {
+				t.Errorf("limit %d: chunk %d is not valid UTF-8", limit, i)
+			}
+			if strings.Count(chunk, codeFence)%2 != 0 {
+				t.Errorf("limit %d: chunk %d has an unclosed code fence: %q", limit, i, chunk)
+			}
+			lines += strings.Count(chunk, "print(")
+		}
+		if lines != 30 {
+			t.Errorf("limit %d: %d code lines survived, want 30", limit, lines)
+		}
+	}
+}
+
+func TestSplitMessageWithLead(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		limit   int
+		leadLen int
+		want    []string
+	}{
+		{"无前缀", "一二三四五六", 6, 0, []string{"一二三四五六"}},
+		{"首段加前缀恰好等于上限", "一二三四", 6, 2, []string{"一二三四"}},
+		{"首段恰好等于上限时为前缀让出空间", "一二三四五六", 6, 2, []string{"一二三四", "五六"}},
+		{"只有首段预留长度", "一二三四五六\n\n七八九十壹贰", 6, 2, []string{"一二三四", "五六", "七八九十壹贰"}},
+		{"前缀超过上限时每段至少一个字符", "一二", 3, 5, []string{"一", "二"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessageWithLead(tt.text, tt.limit, tt.leadLen)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitMessageWithLead(%q, %d, %d) = %q, want %q", tt.text, tt.limit, tt.leadLen, got, tt.want)
+			}
+		})
+	}
+}
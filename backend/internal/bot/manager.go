@@ -2,6 +2,11 @@ package bot
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"sk-im-bot/internal/config"
@@ -9,133 +14,502 @@ import (
 	"sk-im-bot/internal/model"
 	"sk-im-bot/pkg/utils"
 
-	"github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
 )
 
 // BotManager 核心管理器，协调多个平台的机器人适配器与 LLM 逻辑
 type BotManager struct {
-	qqAdapter      BotAdapter        // QQ 平台适配器
-	discordAdapter BotAdapter        // Discord 平台适配器
-	llmClient      *llm.LLMClient    // LLM API 客户端
-	msgChan        chan MessageEvent // 全局异步消息处理通道
+	// adapters 按实例名索引的已启用适配器，回复按事件的 Adapter 字段路由
+	adapters  map[string]*adapterInstance
+	llmClient *llm.LLMClient    // LLM API 客户端
+	chatCfg   config.ChatConfig // 多轮对话记忆配置
+	commands  *CommandRegistry  // 聊天指令注册表，在 LLM 之前处理
+	blacklist *BlacklistCache   // 黑名单内存缓存
+	limiter   *RateLimiter      // LLM 回复限流器
+	pool      *WorkerPool       // 回复生成工作池，按会话串行、跨会话并行
+	startedAt time.Time         // 管理器启动时间
+	msgChan   chan MessageEvent // 全局异步消息处理通道
+
+	// ctx 为所有 LLM 调用的根上下文，停机超时后通过 cancel 中止仍在进行的请求
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closeMu 保护 closing 标记与 msgChan 的关闭，避免向已关闭的通道投递事件
+	closeMu  sync.RWMutex
+	closing  bool
+	inflight sync.WaitGroup // 仍在处理中的事件 (落库、指令、LLM 回复)
+	loopDone chan struct{}  // processLoop 排空队列并退出后关闭
 	// broadcastFunc 用于将收到的实时消息推送到前端 WebSocket
 	broadcastFunc func(msg interface{})
 }
 
+// shutdownGrace 停机超时并取消 LLM 调用后，额外等待处理流程收尾的时长
+const shutdownGrace = 2 * time.Second
+
 // Manager 全局机器人管理器单例
 var Manager *BotManager
 
 // InitManager 初始化管理器实例及启用的各平台适配器
 func InitManager(cfg *config.Config, llmClient *llm.LLMClient, broadcast func(interface{})) {
+	ctx, cancel := context.WithCancel(context.Background())
 	Manager = &BotManager{
+		ctx:           ctx,
+		cancel:        cancel,
+		loopDone:      make(chan struct{}),
 		llmClient:     llmClient,
+		chatCfg:       cfg.Chat,
 		msgChan:       make(chan MessageEvent, 100), // 消息缓冲区容量为 100
 		broadcastFunc: broadcast,
+		adapters:      make(map[string]*adapterInstance),
+		commands:      NewCommandRegistry(cfg.Chat.CommandPrefix),
+		blacklist:     NewBlacklistCache(),
+		limiter:       NewRateLimiter(cfg.RateLimit),
+		pool:          NewWorkerPool(cfg.Worker.Workers, cfg.Worker.QueueSize),
+		startedAt:     time.Now(),
 	}
+	registerBuiltinCommands(Manager.commands)
 
-	// 根据配置决定是否初始化各平台适配器
-	if cfg.QQ.Enabled {
-		Manager.qqAdapter = NewQQBot(cfg.QQ, Manager.HandleEvent)
-	}
-	if cfg.Discord.Enabled {
-		Manager.discordAdapter = NewDiscordBot(cfg.Discord, Manager.HandleEvent)
+	// 根据配置创建全部已启用的适配器实例，单个实例配置有误不影响其他实例
+	for _, spec := range collectInstanceSpecs(cfg) {
+		if _, ok := Manager.adapters[spec.name]; ok {
+			utils.Logger.Error("适配器实例名重复，已忽略", zap.String("实例", spec.name))
+			continue
+		}
+		inst, err := buildInstance(spec, Manager.HandleEvent)
+		if err != nil {
+			utils.Logger.Error("适配器初始化失败", zap.Error(err))
+			continue
+		}
+		if inst != nil {
+			Manager.adapters[inst.name] = inst
+			utils.Logger.Info("已加载适配器实例", zap.String("实例", inst.name), zap.String("平台", inst.platform))
+		}
 	}
 }
 
 // Start 启动所有已激活的适配器并进入主处理循环
 func (m *BotManager) Start() {
-	if m.qqAdapter != nil {
-		go m.qqAdapter.Start()
-	}
-	if m.discordAdapter != nil {
-		go m.discordAdapter.Start()
+	// 适配器接入前先同步加载黑名单，保证最早到达的事件也经过黑名单过滤
+	m.ReloadBlacklist()
+
+	for _, inst := range m.adapters {
+		go func(inst *adapterInstance) {
+			if err := inst.adapter.Start(); err != nil {
+				utils.Logger.Error("适配器启动失败", zap.String("实例", inst.name), zap.Error(err))
+			}
+		}(inst)
 	}
 
+	// 定期与数据库同步黑名单
+	go m.blacklistLoop()
+
 	// 在独立协程中运行消息分发循环
 	go m.processLoop()
 }
 
+// ErrShuttingDown 停机开始后 HandleEvent 拒绝新事件时返回，支持重投的上报方式 (如 Webhook) 应以 503 应答
+var ErrShuttingDown = errors.New("机器人正在停机，暂不接收新事件")
+
 // HandleEvent 接收来自平台协议层（OneBot/Discordgo）的消息并投递到内部队列
-func (m *BotManager) HandleEvent(event MessageEvent) {
+// 停机开始后不再接收新事件并返回 ErrShuttingDown，事件未被确认处理
+func (m *BotManager) HandleEvent(event MessageEvent) error {
+	m.closeMu.RLock()
+	defer m.closeMu.RUnlock()
+	if m.closing {
+		utils.Logger.Warn("机器人正在停机，拒绝新消息事件", zap.String("平台", event.Platform))
+		m.finishInteraction(event)
+		return ErrShuttingDown
+	}
 	m.msgChan <- event
+	return nil
 }
 
-// processLoop 循环处理消息队列中的每一条事件
+// Shutdown 协调停机：停止接收事件、在截止时间内排空队列并等待处理中的回复完成，
+// 超时后取消所有 LLM 调用，最后关闭各平台适配器
+func (m *BotManager) Shutdown(ctx context.Context) {
+	m.closeMu.Lock()
+	if m.closing {
+		m.closeMu.Unlock()
+		return
+	}
+	m.closing = true
+	close(m.msgChan)
+	m.closeMu.Unlock()
+
+	utils.Logger.Info("机器人管理器开始停机，正在排空消息队列", zap.Int("待处理", len(m.msgChan)))
+
+	// 等待队列排空且所有处理中的事件完成
+	done := make(chan struct{})
+	go func() {
+		<-m.loopDone
+		m.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		utils.Logger.Info("消息队列已排空，所有回复处理完毕")
+	case <-ctx.Done():
+		utils.Logger.Warn("停机等待超时，取消仍在进行的 LLM 调用")
+		m.cancel()
+		// 给被取消的处理流程留出少量时间完成收尾写库
+		select {
+		case <-done:
+		case <-time.After(shutdownGrace):
+			utils.Logger.Warn("部分消息处理未能在停机前完成")
+		}
+	}
+	m.cancel()
+	m.pool.Stop()
+
+	// 最后关闭各平台适配器，保证排空阶段的回复仍能正常发出
+	for _, inst := range m.adapters {
+		inst.adapter.Stop()
+	}
+	utils.Logger.Info("机器人管理器已停止")
+}
+
+// processLoop 循环处理消息队列中的每一条事件，通道关闭并排空后退出
 func (m *BotManager) processLoop() {
+	defer close(m.loopDone)
 	for event := range m.msgChan {
 		utils.Logger.Info("处理新消息事件", zap.String("平台", event.Platform), zap.String("内容", event.Content))
 
-		// 1. 异步持久化到数据库
-		go m.saveMessage(event)
+		// 0. 丢弃黑名单用户或群组的消息
+		if m.isBlocked(event) {
+			m.finishInteraction(event)
+			continue
+		}
 
-		// 2. 实时推送到管理控制台
-		if m.broadcastFunc != nil {
+		// 1. 实时推送到管理控制台 (回复控件上的操作不是聊天消息，不推送)
+		if m.broadcastFunc != nil && event.Action == nil {
 			m.broadcastFunc(event)
 		}
 
-		// 3. 处理机器人自动回复逻辑
-		// 备注：此处可扩展判断是否被 @、关键词匹配等
-		go m.handleLLMReply(event)
+		// 2. 提交到工作池异步持久化并处理机器人自动回复逻辑
+		// 同一会话的消息按到达顺序串行处理，必须先落库再构造上下文，保证历史完整
+		m.inflight.Add(1)
+		submitted := m.pool.Submit(sessionKey(event), func() {
+			defer m.inflight.Done()
+			defer m.finishInteraction(event)
+			m.handleMessage(event)
+		}, func() {
+			// 停机时尚未开始处理即被丢弃
+			m.finishInteraction(event)
+			m.inflight.Done()
+		})
+		if !submitted {
+			m.inflight.Done()
+			m.finishInteraction(event)
+			utils.Logger.Warn("工作池队列已满，丢弃消息事件", zap.String("平台", event.Platform), zap.String("目标", event.PlatformID))
+		}
+	}
+}
+
+// handleMessage 处理单条消息事件：落库、指令分发、触发判定、限流与 LLM 回复
+func (m *BotManager) handleMessage(event MessageEvent) {
+	// 交互式指令 (如斜杠指令) 转换为带前缀的指令文本，与聊天中发送的指令走同一流程
+	if event.Command != "" {
+		event.Content = m.commands.Format(event.Command, event.Content)
+	}
+
+	// 回复控件上的操作 (重新生成、评价等) 不作为聊天消息记录
+	if event.Action != nil {
+		m.handleAction(event)
+		return
+	}
+
+	// 指令消息仅作记录，交由指令注册表处理后不再进入 LLM 流程
+	isCommand := m.commands.IsCommand(event.Content)
+	if isCommand {
+		event.MsgType = MsgTypeCommand
+	}
+	session, msg := m.saveMessage(event)
+	if isCommand && m.commands.Dispatch(m, event, session) {
+		return
+	}
+
+	// 按平台触发规则判断是否需要回复 (群聊中仅响应 @、前缀、关键词)
+	content, ok := m.shouldReply(event)
+	if !ok {
+		return
+	}
+	event.Content = content
+
+	// 按用户、群组、平台维度限流，避免刷屏耗尽 API 额度
+	if scope, ok := m.limiter.Allow(event); !ok {
+		m.onRateLimited(event, scope)
+		return
+	}
+
+	// 启用了线程模式的频道中，回复转入为本次对话开启的线程
+	event, session = m.openThread(event, session, msg)
+	m.handleLLMReply(event, session, msg)
+}
+
+// sessionKey 返回事件所属会话的串行化键
+func sessionKey(event MessageEvent) string {
+	return event.Platform + ":" + event.PlatformID
+}
+
+// shouldReply 使用事件来源实例 (或适配器按服务器覆盖) 的触发规则判定是否回复，来源未知时仅回复私聊
+func (m *BotManager) shouldReply(event MessageEvent) (string, bool) {
+	// 交互事件由用户显式发起，始终回复
+	if event.InteractionID != "" {
+		return strings.TrimSpace(event.Content), true
+	}
+	policy := NewTriggerPolicy(config.TriggerConfig{})
+	if inst := m.adapterFor(event.Adapter); inst != nil {
+		policy = inst.trigger
+		if overrider, ok := inst.adapter.(TriggerOverrider); ok {
+			if override := overrider.TriggerFor(event); override != nil {
+				policy = override
+			}
+		}
+	}
+	return policy.Match(event)
+}
+
+// onRateLimited 处理被限流的消息，按冷却时间向用户发送礼貌提示
+func (m *BotManager) onRateLimited(event MessageEvent, scope string) {
+	utils.Logger.Info("LLM 回复被限流", zap.String("平台", event.Platform), zap.String("用户", event.UserID), zap.String("维度", scope))
+	if !m.limiter.ShouldNotice(event) {
+		return
+	}
+	if _, err := m.deliver(event, m.limiter.Notice()); err != nil {
+		utils.Logger.Error("限流提示发送失败", zap.Error(err))
 	}
 }
 
-// saveMessage 将接收到的消息记录保存到 model 层
-func (m *BotManager) saveMessage(event MessageEvent) {
+// saveMessage 将接收到的消息记录保存到 model 层，并返回所属会话与消息记录
+// 数据库异常时会话可能为 nil，此时回复逻辑退化为单轮对话
+func (m *BotManager) saveMessage(event MessageEvent) (*model.Session, *model.Message) {
+	session, err := m.resolveSession(event)
+	if err != nil {
+		utils.Logger.Error("会话解析失败", zap.Error(err))
+	}
+
 	msg := model.Message{
-		Sender:    event.Username,
-		Content:   event.Content,
-		MsgType:   string(event.MsgType),
-		CreatedAt: time.Now(),
+		Sender:        event.Username,
+		SenderID:      event.UserID,
+		PlatformMsgID: event.MessageID,
+		ReplyToMsgID:  event.quoteID(),
+		Content:       event.Content,
+		MsgType:       string(event.MsgType),
+		CreatedAt:     time.Now(),
+	}
+	if session != nil {
+		msg.SessionID = session.ID
 	}
 	if err := model.DB.Create(&msg).Error; err != nil {
 		utils.Logger.Error("消息保存失败", zap.Error(err))
+		return session, nil
 	}
+	if session != nil {
+		m.pruneHistory(session.ID)
+	}
+	return session, &msg
 }
 
 // handleLLMReply 调用 LLM 进行对话生成的逻辑入口
-func (m *BotManager) handleLLMReply(event MessageEvent) {
+func (m *BotManager) handleLLMReply(event MessageEvent, session *model.Session, msg *model.Message) {
+	var triggerID uint
+	if msg != nil {
+		triggerID = msg.ID
+	}
+	m.generateReply(event, session, msg, triggerID, triggerID)
+}
+
+// generateReply 以 msg 为当前消息 (可为 nil，此时以事件正文作为当前消息) 生成并发送回复，
+// 上下文只包含 ID 小于 before 的历史 (为 0 时不限)；发送完成后为回复附加交互控件，triggerID 为控件关联的触发消息
+func (m *BotManager) generateReply(event MessageEvent, session *model.Session, msg *model.Message, triggerID, before uint) {
 	// 【防封号】注入随机等待时间，增加行为仿真度
 	RandomDelay(500, 3000)
 
-	// 构造 LLM 对话上下文（此处可根据 Session 获取历史记录进行多轮对话）
-	messages := []openai.ChatCompletionMessage{
-		{Role: openai.ChatMessageRoleUser, Content: event.Content},
+	// 补全被引用的原消息，随后根据会话历史与引用链构造多轮对话上下文，并注入当前聊天适用的人设
+	event = m.resolveQuote(event)
+	messages := m.buildContext(session, msg, before, event)
+	messages, opts := applyPersona(messages, m.resolvePersona(event), session)
+
+	// 适配器支持渐进式投递时使用流式生成，否则等待完整回答后一次性发送
+	if m.chatCfg.Stream {
+		if replier, ok := m.senderFor(event).(StreamReplier); ok {
+			if recorded, ok := m.streamReply(replier, event, messages, opts); ok {
+				m.attachControls(event, session, triggerID, recorded)
+				return
+			}
+		}
 	}
 
-	response, err := m.llmClient.Chat(context.Background(), messages)
+	response, err := m.llmClient.ChatWithOptions(m.ctx, messages, opts)
 	if err != nil {
 		utils.Logger.Error("LLM 接口调用异常", zap.Error(err))
 		return
 	}
 
 	// 将生成的回答发送回原始平台
-	m.SendReply(event.Platform, event.PlatformID, response, event.IsGroup)
+	recorded := m.SendReply(event, response)
+	m.attachControls(event, session, triggerID, recorded)
+}
+
+// adapterFor 返回指定名称的适配器实例，实例不存在或未启用时返回 nil
+func (m *BotManager) adapterFor(name string) *adapterInstance {
+	return m.adapters[name]
+}
+
+// Endpoints 汇总各适配器实例需要挂载的 HTTP 处理器，路径冲突时保留先注册的实例
+func (m *BotManager) Endpoints() map[string]http.Handler {
+	endpoints := make(map[string]http.Handler)
+	for _, inst := range m.adapters {
+		provider, ok := inst.adapter.(EndpointProvider)
+		if !ok {
+			continue
+		}
+		for path, handler := range provider.Endpoints() {
+			if _, exists := endpoints[path]; exists {
+				utils.Logger.Error("适配器 HTTP 路径冲突，已忽略", zap.String("实例", inst.name), zap.String("路径", path))
+				continue
+			}
+			endpoints[path] = handler
+		}
+	}
+	return endpoints
+}
+
+// finishInteraction 事件处理完毕或被丢弃后结束其交互，避免用户一直看到等待回复的占位响应
+func (m *BotManager) finishInteraction(event MessageEvent) {
+	if event.InteractionID == "" {
+		return
+	}
+	if inst := m.adapterFor(event.Adapter); inst != nil {
+		if interactive, ok := inst.adapter.(InteractionAdapter); ok {
+			interactive.FinishInteraction(event.InteractionID)
+		}
+	}
+}
+
+// senderFor 返回回复事件时使用的适配器，多账号适配器绑定到接收消息的账号
+// 事件来源实例不存在或未启用时返回 nil
+func (m *BotManager) senderFor(event MessageEvent) BotAdapter {
+	inst := m.adapterFor(event.Adapter)
+	if inst == nil {
+		return nil
+	}
+	if interactive, ok := inst.adapter.(InteractionAdapter); ok && event.InteractionID != "" {
+		return interactive.Interaction(event.InteractionID)
+	}
+	if multi, ok := inst.adapter.(MultiAccountAdapter); ok && event.SelfID != "" {
+		return multi.Account(event.SelfID)
+	}
+	return inst.adapter
 }
 
-// SendReply 根据指定的平台分发发送任务
-func (m *BotManager) SendReply(platform, targetID, content string, isGroup bool) {
-	var err error
-	switch platform {
-	case "qq":
-		if m.qqAdapter != nil {
-			err = m.qqAdapter.SendMessage(targetID, content, isGroup)
+// deliver 将内容发送到事件所在的会话，超长内容按实例上限分段并模拟打字节奏依次发送，不做任何记录
+// 返回实际发出的各条消息；中途失败时返回已发出的部分与错误
+func (m *BotManager) deliver(event MessageEvent, content string) ([]SentMessage, error) {
+	sender := m.senderFor(event)
+	if sender == nil {
+		return nil, fmt.Errorf("适配器实例 %q 不存在或未启用", event.Adapter)
+	}
+	var sent []SentMessage
+	lead := m.replyLead(event)
+	leadLen := NewOutboundMessage(lead...).TextLength()
+	for i, chunk := range SplitMessageWithLead(content, m.adapterFor(event.Adapter).maxLength, leadLen) {
+		if i > 0 {
+			RandomDelay(chunkDelay(sent[i-1].Content))
+		}
+		var id string
+		var err error
+		if i == 0 && len(lead) > 0 {
+			// 首段引用触发消息 (或 @提问者)，其余分段直接发送
+			msg := NewOutboundMessage(append(append([]OutboundSegment{}, lead...), Text(chunk))...)
+			id, err = sender.SendRich(event.PlatformID, msg, event.IsGroup)
+		} else {
+			id, err = sender.SendMessage(event.PlatformID, chunk, event.IsGroup)
+		}
+		if err != nil {
+			return sent, err
 		}
-	case "discord":
-		if m.discordAdapter != nil {
-			err = m.discordAdapter.SendMessage(targetID, content, isGroup)
+		sent = append(sent, SentMessage{ID: id, Content: chunk})
+	}
+	return sent, nil
+}
+
+// 群聊回复与触发消息的关联方式
+const (
+	replyModeQuote   = "quote"   // 引用触发消息
+	replyModeMention = "mention" // @提问者
+	replyModeNone    = "none"    // 直接发送
+)
+
+// replyLead 返回回复首条消息需附加的消息段：按实例配置 (可按群覆盖) 引用触发消息，
+// 缺少消息 ID 无法引用时退回 @提问者；私聊、交互响应与 none 模式不附加任何内容
+func (m *BotManager) replyLead(event MessageEvent) []OutboundSegment {
+	inst := m.adapterFor(event.Adapter)
+	if inst == nil || !event.IsGroup || event.InteractionID != "" {
+		return nil // 交互响应本身已关联到发起者
+	}
+	mode := inst.reply.Mode
+	if override, ok := inst.reply.Groups[event.PlatformID]; ok {
+		mode = override
+	}
+
+	switch mode {
+	case replyModeNone:
+		return nil
+	case replyModeMention:
+	default: // replyModeQuote，未配置时同样引用
+		if event.MessageID != "" {
+			return []OutboundSegment{ReplyTo(event.MessageID)}
 		}
 	}
+	if event.UserID == "" {
+		return nil
+	}
+	return []OutboundSegment{Mention(event.UserID, event.Username), Text(" ")}
+}
 
+// SendReply 回复事件所在的会话 (经由接收消息的实例与账号)，并将成功发送的每一段回复计入会话历史
+// 返回已保存的回复记录
+func (m *BotManager) SendReply(event MessageEvent, content string) []model.Message {
+	sent, err := m.deliver(event, content)
+	var recorded []model.Message
+	for _, msg := range sent {
+		if record := m.recordReply(event, msg); record != nil {
+			recorded = append(recorded, *record)
+		}
+	}
 	if err != nil {
-		utils.Logger.Error("回复发送失败", zap.String("平台", platform), zap.Error(err))
-	} else {
-		// 成功发送后将机器人自身的回复也存入数据库
-		model.DB.Create(&model.Message{
-			Sender:    "bot",
-			Content:   content,
-			MsgType:   "text",
-			CreatedAt: time.Now(),
-		})
+		utils.Logger.Error("回复发送失败", zap.String("实例", event.Adapter), zap.Error(err))
+	}
+	return recorded
+}
+
+// recordReply 将机器人成功发出的回复存入数据库，归属到对应会话以便后续多轮对话
+// 回复关联到触发它的消息，用户之后引用这条回复时可沿引用链还原上下文；保存失败时返回 nil
+func (m *BotManager) recordReply(event MessageEvent, sent SentMessage) *model.Message {
+	msg := model.Message{
+		Sender:        botSender,
+		Content:       sent.Content,
+		PlatformMsgID: sent.ID,
+		ReplyToMsgID:  event.MessageID,
+		MsgType:       "text",
+		CreatedAt:     time.Now(),
+	}
+	if session := m.findSession(event.Platform, event.PlatformID); session != nil {
+		msg.SessionID = session.ID
+	}
+	if err := model.DB.Create(&msg).Error; err != nil {
+		utils.Logger.Error("回复记录保存失败", zap.Error(err))
+		return nil
+	}
+	return &msg
+}
+
+// toSet 将字符串列表转换为便于查找的集合
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
 	}
+	return set
 }
@@ -0,0 +1,40 @@
+package bot
+
+import "testing"
+
+// interactionRecorder 记录被结束的交互的适配器
+type interactionRecorder struct {
+	finished []string
+}
+
+func (a *interactionRecorder) Start() error { return nil }
+func (a *interactionRecorder) Stop()        {}
+func (a *interactionRecorder) SendMessage(targetID, content string, isGroup bool) (string, error) {
+	return "", nil
+}
+func (a *interactionRecorder) SendImage(targetID, imageUrl string, isGroup bool) error { return nil }
+func (a *interactionRecorder) SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error) {
+	return "", nil
+}
+func (a *interactionRecorder) Interaction(id string) BotAdapter { return a }
+func (a *interactionRecorder) FinishInteraction(id string) {
+	a.finished = append(a.finished, id)
+}
+
+func TestHandleEventFinishesInteractionWhenClosing(t *testing.T) {
+	logs := observeLogs(t)
+	adapter := &interactionRecorder{}
+	m := &BotManager{
+		adapters: map[string]*adapterInstance{"discord": {name: "discord", adapter: adapter}},
+		closing:  true,
+	}
+	if err := m.HandleEvent(MessageEvent{Platform: "discord", Adapter: "discord", InteractionID: "i1"}); err != ErrShuttingDown {
+		t.Errorf("HandleEvent() = %v, want ErrShuttingDown", err)
+	}
+	if len(adapter.finished) != 1 || adapter.finished[0] != "i1" {
+		t.Errorf("finished = %v, want [i1]", adapter.finished)
+	}
+	if logs.FilterMessageSnippet("停机").Len() != 1 {
+		t.Error("丢弃事件时未记录日志")
+	}
+}
@@ -0,0 +1,186 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// qqActionTimeout 等待 OneBot 动作响应的最长时间
+const qqActionTimeout = 10 * time.Second
+
+// OneBotAction 定义发送给端点的 API 调用结构
+type OneBotAction struct {
+	Action string      `json:"action"` // 动作名称
+	Params interface{} `json:"params"` // 参数负载
+	Echo   string      `json:"echo"`   // 回显标识，响应中原样返回，用于关联调用与响应
+}
+
+// OneBotResponse 动作调用的响应
+type OneBotResponse struct {
+	Status  string          `json:"status"`  // ok, async 或 failed
+	Retcode int             `json:"retcode"` // 返回码，0 表示成功
+	Data    json.RawMessage `json:"data"`    // 响应数据
+	Message string          `json:"message"` // 错误信息 (部分实现)
+	Wording string          `json:"wording"` // 可读的错误描述 (部分实现)
+	Echo    string          `json:"echo"`    // 调用时携带的回显标识
+}
+
+// decode 检查响应状态，成功时将响应数据解析到 out (可为 nil)
+func (r *OneBotResponse) decode(action string, out interface{}) error {
+	if r.Status == "failed" || (r.Retcode != 0 && r.Status != "async") {
+		// wording 为部分实现提供的可读描述，优先使用
+		reason := r.Wording
+		if reason == "" {
+			reason = r.Message
+		}
+		return fmt.Errorf("OneBot %s 调用失败 (retcode %d): %s", action, r.Retcode, reason)
+	}
+	if out == nil || len(r.Data) == 0 || string(r.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(r.Data, out); err != nil {
+		return fmt.Errorf("OneBot %s 响应数据解析失败: %w", action, err)
+	}
+	return nil
+}
+
+// OneBotID 兼容数字与字符串两种形式的 ID (不同 OneBot 实现的返回类型不一致)
+type OneBotID string
+
+// UnmarshalJSON 同时接受 JSON 数字与字符串
+func (id *OneBotID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*id = ""
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*id = OneBotID(str)
+		return nil
+	}
+	*id = OneBotID(s)
+	return nil
+}
+
+// idParam 将 ID 转换为动作参数：纯数字时按数字传递 (OneBot 11 规范要求)，否则保留字符串
+func idParam(id string) interface{} {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return n
+	}
+	return id
+}
+
+// OneBotSender 消息发送者信息
+type OneBotSender struct {
+	UserID   OneBotID `json:"user_id"`
+	Nickname string   `json:"nickname"`
+	Card     string   `json:"card"` // 群名片
+	Role     string   `json:"role"` // 群内身份 (owner, admin, member)
+}
+
+// OneBotMessage get_msg 返回的消息详情
+type OneBotMessage struct {
+	MessageID   OneBotID        `json:"message_id"`
+	RealID      OneBotID        `json:"real_id"`
+	MessageType string          `json:"message_type"` // private 或 group
+	Time        int64           `json:"time"`
+	Sender      OneBotSender    `json:"sender"`
+	Message     json.RawMessage `json:"message"`     // 消息内容 (CQ 码字符串或消息段数组)
+	RawMessage  string          `json:"raw_message"` // CQ 码形式的原始消息 (部分实现提供)
+}
+
+// OneBotGroupMember get_group_member_info 返回的群成员信息
+type OneBotGroupMember struct {
+	GroupID      OneBotID `json:"group_id"`
+	UserID       OneBotID `json:"user_id"`
+	Nickname     string   `json:"nickname"`
+	Card         string   `json:"card"`
+	Role         string   `json:"role"`
+	Title        string   `json:"title"` // 专属头衔
+	JoinTime     int64    `json:"join_time"`
+	LastSentTime int64    `json:"last_sent_time"`
+}
+
+// OneBotGroup get_group_list 返回的群信息
+type OneBotGroup struct {
+	GroupID        OneBotID `json:"group_id"`
+	GroupName      string   `json:"group_name"`
+	MemberCount    int      `json:"member_count"`
+	MaxMemberCount int      `json:"max_member_count"`
+}
+
+// OneBotAPI 绑定到指定账号的 OneBot 动作客户端，调用会等待并校验响应
+type OneBotAPI struct {
+	bot    *QQBot
+	selfID string
+}
+
+// API 返回指定账号的动作客户端，selfID 为空时使用默认连接
+func (q *QQBot) API(selfID string) *OneBotAPI {
+	return &OneBotAPI{bot: q, selfID: selfID}
+}
+
+// Call 调用任意 OneBot 动作，并将响应数据解析到 out (可为 nil)
+func (a *OneBotAPI) Call(action string, params interface{}, out interface{}) error {
+	return a.bot.callAction(a.selfID, action, params, out)
+}
+
+// SendMsg 发送由消息段组成的私聊或群消息，返回消息 ID
+// 消息段应由程序构造；来自用户或 LLM 的文本请使用 TextSegment 包装，避免 CQ 码注入
+func (a *OneBotAPI) SendMsg(targetID string, segments []MessageSegment, isGroup bool) (string, error) {
+	return a.bot.sendAs(a.selfID, targetID, segments, isGroup)
+}
+
+// GetMsg 获取指定消息的详情
+func (a *OneBotAPI) GetMsg(messageID string) (*OneBotMessage, error) {
+	var msg OneBotMessage
+	if err := a.Call("get_msg", map[string]interface{}{"message_id": idParam(messageID)}, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// DeleteMsg 撤回指定消息
+func (a *OneBotAPI) DeleteMsg(messageID string) error {
+	return a.Call("delete_msg", map[string]interface{}{"message_id": idParam(messageID)}, nil)
+}
+
+// GetGroupMemberInfo 获取群成员信息，noCache 为 true 时要求实现绕过缓存
+func (a *OneBotAPI) GetGroupMemberInfo(groupID, userID string, noCache bool) (*OneBotGroupMember, error) {
+	var member OneBotGroupMember
+	params := map[string]interface{}{
+		"group_id": idParam(groupID),
+		"user_id":  idParam(userID),
+		"no_cache": noCache,
+	}
+	if err := a.Call("get_group_member_info", params, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetGroupList 获取账号加入的全部群
+func (a *OneBotAPI) GetGroupList() ([]OneBotGroup, error) {
+	var groups []OneBotGroup
+	if err := a.Call("get_group_list", struct{}{}, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// SetGroupBan 禁言群成员，duration 为 0 时解除禁言 (精度为秒)
+func (a *OneBotAPI) SetGroupBan(groupID, userID string, duration time.Duration) error {
+	params := map[string]interface{}{
+		"group_id": idParam(groupID),
+		"user_id":  idParam(userID),
+		"duration": int64(duration / time.Second),
+	}
+	return a.Call("set_group_ban", params, nil)
+}
@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutboundSegmentType 平台无关的出站消息段类型
+type OutboundSegmentType string
+
+const (
+	OutboundText    OutboundSegmentType = "text"    // 纯文本，不会被任何平台解析为控制代码
+	OutboundMention OutboundSegmentType = "mention" // @某人，UserID 为 MentionAll 时表示 @全体成员
+	OutboundImage   OutboundSegmentType = "image"   // 图片，URL 与 Data 二选一
+	OutboundReply   OutboundSegmentType = "reply"   // 引用回复，MessageID 为被回复的消息 ID
+	OutboundFile    OutboundSegmentType = "file"    // 文件，URL 与 Data 二选一
+	OutboundEmbed   OutboundSegmentType = "embed"   // 结构化卡片 (标题、字段、代码)，不支持卡片的平台渲染为文本
+)
+
+// MentionAll @全体成员 时使用的 UserID
+const MentionAll = "all"
+
+// OutboundSegment 出站消息段，各字段按类型选用
+type OutboundSegment struct {
+	Type      OutboundSegmentType
+	Text      string // 文本内容 (text)
+	UserID    string // 被 @ 的用户 ID (mention)
+	Name      string // 被 @ 用户的显示名 (mention) 或文件名 (image/file)
+	URL       string // 图片或文件的网络地址 (image/file)
+	Data      []byte // 图片或文件的二进制内容 (image/file)
+	MessageID string // 被回复的消息 ID (reply)
+	Embed     *Embed // 卡片内容 (embed)
+}
+
+// OutboundMessage 平台无关的富消息，由适配器的 SendRich 渲染为平台原生格式
+// 其中的文本段始终按纯文本发送，只有程序显式构造的消息段才会成为 @、图片等富内容
+type OutboundMessage struct {
+	Segments []OutboundSegment
+}
+
+// NewOutboundMessage 由若干消息段组成一条富消息
+func NewOutboundMessage(segments ...OutboundSegment) OutboundMessage {
+	return OutboundMessage{Segments: segments}
+}
+
+// Text 构造纯文本出站消息段
+func Text(text string) OutboundSegment {
+	return OutboundSegment{Type: OutboundText, Text: text}
+}
+
+// Mention 构造 @某人 的出站消息段，name 用于不支持按 ID 提及的平台展示
+func Mention(userID, name string) OutboundSegment {
+	return OutboundSegment{Type: OutboundMention, UserID: userID, Name: name}
+}
+
+// ImageURL 构造网络图片出站消息段
+func ImageURL(url string) OutboundSegment {
+	return OutboundSegment{Type: OutboundImage, URL: url}
+}
+
+// ImageData 构造二进制图片出站消息段，name 为附件文件名 (如 chart.png)
+func ImageData(name string, data []byte) OutboundSegment {
+	return OutboundSegment{Type: OutboundImage, Name: name, Data: data}
+}
+
+// ReplyTo 构造引用回复出站消息段
+func ReplyTo(messageID string) OutboundSegment {
+	return OutboundSegment{Type: OutboundReply, MessageID: messageID}
+}
+
+// FileURL 构造网络文件出站消息段
+func FileURL(name, url string) OutboundSegment {
+	return OutboundSegment{Type: OutboundFile, Name: name, URL: url}
+}
+
+// FileData 构造二进制文件出站消息段
+func FileData(name string, data []byte) OutboundSegment {
+	return OutboundSegment{Type: OutboundFile, Name: name, Data: data}
+}
+
+// LocalImage 读取本地图片文件构造出站消息段，附件以原文件名上传
+func LocalImage(path string) (OutboundSegment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return OutboundSegment{}, err
+	}
+	return ImageData(filepath.Base(path), data), nil
+}
+
+// LocalFile 读取本地文件构造出站消息段，附件以原文件名上传
+func LocalFile(path string) (OutboundSegment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return OutboundSegment{}, err
+	}
+	return FileData(filepath.Base(path), data), nil
+}
+
+// ImageSource 按 SendImage 接受的图片地址构造出站消息段：http(s) 地址保持为网络图片，
+// base64:// 与 file:// 地址以及本地路径读取为二进制内容，以便作为附件上传
+func ImageSource(src string) (OutboundSegment, error) {
+	switch {
+	case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+		return ImageURL(src), nil
+	case strings.HasPrefix(src, "base64://"):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(src, "base64://"))
+		if err != nil {
+			return OutboundSegment{}, fmt.Errorf("图片 base64 内容解析失败: %w", err)
+		}
+		return ImageData("", data), nil
+	default:
+		return LocalImage(strings.TrimPrefix(src, "file://"))
+	}
+}
+
+// EmbedCard 构造卡片出站消息段
+func EmbedCard(embed Embed) OutboundSegment {
+	return OutboundSegment{Type: OutboundEmbed, Embed: &embed}
+}
+
+// ReplyTarget 返回消息引用回复的目标消息 ID，不含引用回复时为空
+func (m OutboundMessage) ReplyTarget() string {
+	for _, seg := range m.Segments {
+		if seg.Type == OutboundReply {
+			return seg.MessageID
+		}
+	}
+	return ""
+}
+
+// PlainText 返回消息的纯文本投影，用于记录会话历史
+func (m OutboundMessage) PlainText() string {
+	var b strings.Builder
+	for _, seg := range m.Segments {
+		switch seg.Type {
+		case OutboundText:
+			b.WriteString(seg.Text)
+		case OutboundMention:
+			b.WriteString("@" + seg.mentionName())
+		case OutboundImage:
+			b.WriteString("[图片]")
+		case OutboundFile:
+			b.WriteString("[文件]")
+		case OutboundEmbed:
+			b.WriteString(seg.Embed.Text())
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// TextLength 返回消息渲染到正文中的字符数 (取各平台中最长的写法)，用于为首段前附加的引用、@ 预留长度
+// @ 按 Discord 的 <@id> 与 Telegram、QQ 的 @名称 中较长者计算，引用回复与媒体不占正文长度
+func (m OutboundMessage) TextLength() int {
+	n := 0
+	for _, seg := range m.Segments {
+		switch seg.Type {
+		case OutboundText:
+			n += runeLen(seg.Text)
+		case OutboundMention:
+			n += max(runeLen("<@"+seg.UserID+">"), runeLen("@"+seg.mentionName()))
+		case OutboundEmbed:
+			n += runeLen(seg.Embed.Text())
+		}
+	}
+	return n
+}
+
+// mentionName 返回 @ 的展示名称
+func (s OutboundSegment) mentionName() string {
+	switch {
+	case s.UserID == MentionAll:
+		return "全体成员"
+	case s.Name != "":
+		return s.Name
+	default:
+		return s.UserID
+	}
+}
+
+// fileName 返回附件文件名，未指定时按内容类型生成
+func (s OutboundSegment) fileName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Type == OutboundImage {
+		switch http.DetectContentType(s.Data) {
+		case "image/jpeg":
+			return "image.jpg"
+		case "image/gif":
+			return "image.gif"
+		case "image/webp":
+			return "image.webp"
+		default:
+			return "image.png"
+		}
+	}
+	return "file"
+}
+
+// source 返回 OneBot 等平台可识别的资源地址：网络地址原样返回，二进制内容编码为 base64://
+func (s OutboundSegment) source() string {
+	if len(s.Data) > 0 {
+		return "base64://" + base64.StdEncoding.EncodeToString(s.Data)
+	}
+	return s.URL
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"sk-im-bot/internal/llm"
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// chatScope 返回事件所在聊天对应的人设绑定范围
+func chatScope(event MessageEvent) string {
+	if event.IsGroup {
+		return model.PersonaScopeGroup
+	}
+	return model.PersonaScopePrivate
+}
+
+// resolvePersona 按 群聊/私聊 > 父频道 > 平台 > 全局 的优先级解析事件适用的人设，未绑定时返回 nil
+func (m *BotManager) resolvePersona(event MessageEvent) *model.Persona {
+	targets := []string{event.PlatformID}
+	if event.ParentID != "" {
+		targets = append(targets, event.ParentID)
+	}
+	var bindings []model.PersonaBinding
+	err := model.DB.Preload("Persona").
+		Where("(scope = ? AND platform = ? AND target_id IN ?) OR (scope = ? AND platform = ?) OR scope = ?",
+			chatScope(event), event.Platform, targets,
+			model.PersonaScopePlatform, event.Platform,
+			model.PersonaScopeGlobal).
+		Find(&bindings).Error
+	if err != nil {
+		utils.Logger.Error("人设解析失败", zap.Error(err))
+		return nil
+	}
+
+	priority := map[string]int{
+		model.PersonaScopeGroup:    3,
+		model.PersonaScopePrivate:  3,
+		model.PersonaScopePlatform: 2,
+		model.PersonaScopeGlobal:   1,
+	}
+	// 子话题自身的绑定优先于父频道的绑定
+	rank := func(b *model.PersonaBinding) int {
+		r := priority[b.Scope] * 2
+		if b.Scope == chatScope(event) && b.TargetID == event.PlatformID {
+			r++
+		}
+		return r
+	}
+	var best *model.PersonaBinding
+	for i := range bindings {
+		if best == nil || rank(&bindings[i]) > rank(best) {
+			best = &bindings[i]
+		}
+	}
+	if best == nil || best.Persona.ID == 0 {
+		return nil
+	}
+	return &best.Persona
+}
+
+// bindChatPersona 将人设绑定到事件所在的群聊或私聊，persona 为 nil 时解除绑定
+func bindChatPersona(event MessageEvent, persona *model.Persona) error {
+	scope := chatScope(event)
+	if persona == nil {
+		return model.DB.Where("scope = ? AND platform = ? AND target_id = ?", scope, event.Platform, event.PlatformID).
+			Delete(&model.PersonaBinding{}).Error
+	}
+
+	binding := model.PersonaBinding{
+		Scope:     scope,
+		Platform:  event.Platform,
+		TargetID:  event.PlatformID,
+		PersonaID: persona.ID,
+	}
+	return model.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "platform"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"persona_id"}),
+	}).Create(&binding).Error
+}
+
+// applyPersona 为对话注入人设的系统提示词，并生成请求参数
+// 模型优先级: 会话覆盖 (/model) > 人设 > 全局配置
+func applyPersona(messages []openai.ChatCompletionMessage, persona *model.Persona, session *model.Session) ([]openai.ChatCompletionMessage, llm.ChatOptions) {
+	var opts llm.ChatOptions
+	if persona != nil {
+		if persona.SystemPrompt != "" {
+			system := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: persona.SystemPrompt}
+			messages = append([]openai.ChatCompletionMessage{system}, messages...)
+		}
+		opts = llm.ChatOptions{
+			Model:       persona.Model,
+			Temperature: persona.Temperature,
+			TopP:        persona.TopP,
+			MaxTokens:   persona.MaxTokens,
+		}
+	}
+	if session != nil && session.Model != "" {
+		opts.Model = session.Model
+	}
+	return messages, opts
+}
@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"sync"
+)
+
+// sessionQueue 单个会话的待处理任务队列，同一时刻最多只被一个 worker 处理
+type sessionQueue struct {
+	key   string
+	tasks []poolTask
+}
+
+// poolTask 排队中的任务，停机时未执行即被丢弃的任务调用 discard (可为 nil) 通知提交方
+type poolTask struct {
+	run     func()
+	discard func()
+}
+
+// PoolStats 工作池运行指标快照
+type PoolStats struct {
+	Workers        int     `json:"workers"`         // worker 总数
+	Busy           int     `json:"busy"`            // 正在执行任务的 worker 数
+	Pending        int     `json:"pending"`         // 排队中尚未开始的任务数 (队列深度)
+	ActiveSessions int     `json:"active_sessions"` // 有任务排队或执行中的会话数
+	Processed      uint64  `json:"processed"`       // 累计完成的任务数
+	Dropped        uint64  `json:"dropped"`         // 因队列已满或停机被丢弃的任务数
+	Utilization    float64 `json:"utilization"`     // worker 利用率 (Busy / Workers)
+}
+
+// WorkerPool 固定数量的 worker 协程池，按会话键串行执行任务
+// 同一会话内的任务严格按提交顺序执行，不同会话之间并行处理；
+// worker 每执行完一个任务就把会话放回就绪队列末尾，避免单个活跃会话长期占用 worker
+type WorkerPool struct {
+	mu         sync.Mutex
+	queues     map[string]*sessionQueue // 有任务排队或执行中的会话
+	ready      chan *sessionQueue       // 等待 worker 处理的会话
+	workers    int
+	maxPending int
+	pending    int
+	busy       int
+	processed  uint64
+	dropped    uint64
+	closed     bool
+}
+
+// NewWorkerPool 创建并启动工作池，maxPending 为允许排队的最大任务数
+func NewWorkerPool(workers, maxPending int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxPending < 1 {
+		maxPending = 1
+	}
+	p := &WorkerPool{
+		queues:     make(map[string]*sessionQueue),
+		ready:      make(chan *sessionQueue, maxPending), // 每个会话至多在就绪队列中出现一次，容量足够不会阻塞
+		workers:    workers,
+		maxPending: maxPending,
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Submit 将任务追加到指定会话的队列，队列已满或工作池已关闭时返回 false
+// 已接受的任务若在停机前未能开始执行，会改为调用 discard (可为 nil)，提交方借此释放为任务占用的计数等资源
+func (p *WorkerPool) Submit(key string, task func(), discard func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.pending >= p.maxPending {
+		p.dropped++
+		return false
+	}
+
+	p.pending++
+	q, ok := p.queues[key]
+	if ok {
+		// 会话已在就绪队列或正在执行，追加即可，由当前持有者继续处理
+		q.tasks = append(q.tasks, poolTask{run: task, discard: discard})
+		return true
+	}
+	q = &sessionQueue{key: key, tasks: []poolTask{{run: task, discard: discard}}}
+	p.queues[key] = q
+	p.ready <- q
+	return true
+}
+
+// worker 从就绪队列取出会话并执行其队首任务
+func (p *WorkerPool) worker() {
+	for q := range p.ready {
+		p.mu.Lock()
+		if p.closed {
+			// 停机后就绪队列中残留的会话不再执行
+			dropped := p.drain(q)
+			p.mu.Unlock()
+			discardAll(dropped)
+			continue
+		}
+		task := q.tasks[0]
+		q.tasks = q.tasks[1:]
+		p.pending--
+		p.busy++
+		p.mu.Unlock()
+
+		task.run()
+
+		var dropped []poolTask
+		p.mu.Lock()
+		p.busy--
+		p.processed++
+		switch {
+		case len(q.tasks) == 0:
+			delete(p.queues, q.key)
+		case p.closed:
+			// 停机后不再调度剩余任务
+			dropped = p.drain(q)
+		default:
+			p.ready <- q
+		}
+		p.mu.Unlock()
+		discardAll(dropped)
+	}
+}
+
+// drain 移除会话中尚未执行的全部任务并计入丢弃数，调用方需持有锁
+func (p *WorkerPool) drain(q *sessionQueue) []poolTask {
+	dropped := q.tasks
+	q.tasks = nil
+	p.dropped += uint64(len(dropped))
+	p.pending -= len(dropped)
+	delete(p.queues, q.key)
+	return dropped
+}
+
+// discardAll 通知被丢弃任务的提交方 (在锁外调用，避免回调中再次访问工作池时死锁)
+func discardAll(tasks []poolTask) {
+	for _, task := range tasks {
+		if task.discard != nil {
+			task.discard()
+		}
+	}
+}
+
+// Stop 关闭工作池，worker 执行完当前任务后退出，尚未开始的任务被丢弃
+// 调用方应在所有任务完成 (或等待超时) 之后再调用
+func (p *WorkerPool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.ready)
+}
+
+// Stats 返回工作池当前的运行指标
+func (p *WorkerPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Workers:        p.workers,
+		Busy:           p.busy,
+		Pending:        p.pending,
+		ActiveSessions: len(p.queues),
+		Processed:      p.processed,
+		Dropped:        p.dropped,
+		Utilization:    float64(p.busy) / float64(p.workers),
+	}
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolStopDiscardsQueuedTasks(t *testing.T) {
+	p := NewWorkerPool(1, 10)
+	var inflight sync.WaitGroup
+	var ran, discarded atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+
+	submit := func(key string, run func()) {
+		inflight.Add(1)
+		ok := p.Submit(key, func() {
+			defer inflight.Done()
+			ran.Add(1)
+			if run != nil {
+				run()
+			}
+		}, func() {
+			discarded.Add(1)
+			inflight.Done()
+		})
+		if !ok {
+			t.Fatalf("Submit(%q) rejected", key)
+		}
+	}
+
+	// 唯一的 worker 被第一个任务占用，其余任务 (同会话与其他会话) 均在排队
+	submit("a", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	submit("a", nil)
+	submit("b", nil)
+	submit("c", nil)
+
+	p.Stop()
+	close(release)
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("丢弃的任务未释放计数，等待处理中的任务永远不会结束")
+	}
+	if ran.Load() != 1 || discarded.Load() != 3 {
+		t.Errorf("ran = %d, discarded = %d, want 1 and 3", ran.Load(), discarded.Load())
+	}
+	if stats := p.Stats(); stats.Dropped != 3 || stats.Pending != 0 || stats.ActiveSessions != 0 {
+		t.Errorf("stats = %+v, want 3 dropped and nothing pending", stats)
+	}
+	if p.Submit("d", func() {}, nil) {
+		t.Error("Submit after Stop accepted")
+	}
+}
@@ -1,10 +1,19 @@
 package bot
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"sk-im-bot/internal/config"
@@ -14,33 +23,113 @@ import (
 	"go.uber.org/zap"
 )
 
-// QQBot 适配器，通过 OneBot 11 协议对接 QQ 客户端 (如 go-cqhttp)
+// QQ 适配器支持的 OneBot 11 连接模式
+const (
+	qqModeForwardWS = "ws"         // 正向 WebSocket：由本服务连接 OneBot
+	qqModeReverseWS = "reverse_ws" // 反向 WebSocket：由 OneBot 连接本服务
+	qqModeHTTP      = "http"       // HTTP POST 接收事件上报，通过 HTTP API 调用动作
+)
+
+// QQBot 适配器，通过 OneBot 11 协议对接 QQ 客户端 (如 go-cqhttp、NapCat、Lagrange)
 type QQBot struct {
-	cfg         config.QQConfig    // QQ 配置（服务器地址、访问链等）
-	conn        *websocket.Conn    // 后端与 OneBot 端的 WebSocket 连接
-	mu          sync.Mutex         // 互斥锁，确保并发写操作安全
-	handler     func(MessageEvent) // 消息接收回调处理逻辑
-	isConnected bool               // 运行时的连接状态标记
+	name        string                     // 适配器实例名
+	cfg         config.QQConfig            // QQ 配置（连接模式、服务器地址、访问凭据等）
+	conn        *websocket.Conn            // 正向 WebSocket 连接
+	mu          sync.Mutex                 // 互斥锁，确保并发写操作安全
+	handler     func(MessageEvent) error   // 消息接收回调处理逻辑
+	isConnected bool                       // 运行时的连接状态标记
+	accounts    map[string]*websocket.Conn // 反向 WebSocket 模式下按 self_id 索引的 API 连接，每个账号一条
+	reverse     map[*websocket.Conn]bool   // 当前接入的全部反向 WebSocket 连接，停止时统一关闭
+	httpClient  *http.Client               // http 模式下调用 OneBot HTTP API 的客户端
+	done        chan struct{}              // Stop 调用后关闭，用于终止重连循环
+	stopOnce    sync.Once
+
+	seenMu sync.Mutex           // 保护 seen
+	seen   map[string]time.Time // 近期已分发的群消息，多个账号在同一群时同一条消息只处理一次
+
+	echoSeq   atomic.Uint64                   // 动作 echo 序号
+	pendingMu sync.Mutex                      // 保护 pending
+	pending   map[string]chan *OneBotResponse // 按 echo 索引的等待响应的动作调用
+}
+
+// qqDefaultMaxLength QQ 单条消息的默认字符数上限
+const qqDefaultMaxLength = 1500
+
+// qqReverseUpgrader 反向 WebSocket 的协议升级器，OneBot 实现不是浏览器，无需校验 Origin
+var qqReverseUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func init() {
+	RegisterAdapterType(AdapterType{Name: "qq", Section: "qq", Factory: newQQAdapter})
+}
+
+// newQQAdapter 解析 QQ 实例配置并创建适配器
+func newQQAdapter(name string, settings map[string]interface{}, handler func(MessageEvent) error) (BotAdapter, config.AdapterConfig, error) {
+	cfg := config.QQConfig{
+		AdapterConfig: config.AdapterConfig{
+			Trigger:   config.TriggerConfig{Mention: true},
+			MaxLength: qqDefaultMaxLength,
+		},
+		Mode: qqModeForwardWS,
+	}
+	if err := config.DecodeSection(settings, &cfg); err != nil {
+		return nil, cfg.AdapterConfig, err
+	}
+	switch cfg.Mode {
+	case qqModeForwardWS, qqModeReverseWS:
+	case qqModeHTTP:
+		if cfg.HTTPURL == "" {
+			return nil, cfg.AdapterConfig, fmt.Errorf("http 模式需要配置 http_url")
+		}
+	default:
+		return nil, cfg.AdapterConfig, fmt.Errorf("不支持的 QQ 连接模式 %q (可选 ws, reverse_ws, http)", cfg.Mode)
+	}
+	return NewQQBot(name, cfg, handler), cfg.AdapterConfig, nil
 }
 
 // NewQQBot 构造一个全新的 QQ 机器人适配器
-func NewQQBot(cfg config.QQConfig, handler func(MessageEvent)) *QQBot {
+func NewQQBot(name string, cfg config.QQConfig, handler func(MessageEvent) error) *QQBot {
 	return &QQBot{
-		cfg:     cfg,
-		handler: handler,
+		name:       name,
+		cfg:        cfg,
+		handler:    handler,
+		accounts:   make(map[string]*websocket.Conn),
+		reverse:    make(map[*websocket.Conn]bool),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		done:       make(chan struct{}),
+		pending:    make(map[string]chan *OneBotResponse),
+		seen:       make(map[string]time.Time),
 	}
 }
 
-// Start 启动对 OneBot 服务器的长连接监听，包含掉线重连逻辑
+// Start 启动适配器：正向 WebSocket 模式下建立长连接并自动重连；
+// 反向 WebSocket 与 HTTP 模式下由 OneBot 主动连入，仅需等待 Endpoints 挂载的路由接收请求
 func (q *QQBot) Start() error {
+	switch q.cfg.Mode {
+	case qqModeReverseWS:
+		utils.Logger.Info("QQ 适配器等待 OneBot 反向 WebSocket 连入", zap.String("实例", q.name), zap.String("路径", q.reversePath()))
+		return nil
+	case qqModeHTTP:
+		utils.Logger.Info("QQ 适配器等待 OneBot HTTP 事件上报", zap.String("实例", q.name), zap.String("路径", q.postPath()), zap.String("api", q.cfg.HTTPURL))
+		return nil
+	}
+
 	utils.Logger.Info("正在尝试连接到 QQ OneBot 服务...", zap.String("url", q.cfg.WSURL))
 
-	// 无限重连循环，确保服务高可用
+	// 重连循环，确保服务高可用，直到 Stop 被调用
 	for {
+		if q.stopped() {
+			return nil
+		}
 		err := q.connect()
 		if err != nil {
 			utils.Logger.Error("QQ 连接失败，5秒后重试...", zap.Error(err))
-			time.Sleep(5 * time.Second)
+			select {
+			case <-time.After(5 * time.Second):
+			case <-q.done:
+				return nil
+			}
 			continue
 		}
 
@@ -48,16 +137,31 @@ func (q *QQBot) Start() error {
 		for {
 			_, message, err := q.conn.ReadMessage()
 			if err != nil {
-				utils.Logger.Error("QQ 连接断开 (读取错误)", zap.Error(err))
+				q.mu.Lock()
 				q.isConnected = false
+				q.mu.Unlock()
+				if q.stopped() {
+					return nil
+				}
+				utils.Logger.Error("QQ 连接断开 (读取错误)", zap.Error(err))
 				break
 			}
-			// 异步处理收到的原始二进制/Json 数据
-			go q.parseMessage(message)
+			// 按接收顺序同步解析，保证同一会话的消息顺序不被打乱
+			q.dispatch(message)
 		}
 	}
 }
 
+// stopped 判断适配器是否已被停止
+func (q *QQBot) stopped() bool {
+	select {
+	case <-q.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // connect 实际执行向 OneBot 协议端握手
 func (q *QQBot) connect() error {
 	u, err := url.Parse(q.cfg.WSURL)
@@ -65,43 +169,205 @@ func (q *QQBot) connect() error {
 		return err
 	}
 
-	// 使用 gorilla/websocket 向目标地址（通常是端点侧的 WebSocket 正向监听）拨号
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	// 使用 gorilla/websocket 向目标地址（通常是端点侧的 WebSocket 正向监听）拨号，携带访问凭据
+	c, _, err := websocket.DefaultDialer.Dial(u.String(), q.authHeader())
 	if err != nil {
 		return err
 	}
 
+	q.mu.Lock()
 	q.conn = c
 	q.isConnected = true
+	q.mu.Unlock()
 	utils.Logger.Info("成功建立 QQ OneBot WebSocket 通讯")
 	return nil
 }
 
-// Stop 优雅关闭机器人连接
+// Stop 优雅关闭机器人连接，并终止重连循环
 func (q *QQBot) Stop() {
+	q.stopOnce.Do(func() { close(q.done) })
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	if q.conn != nil {
-		q.conn.Close()
+		closeWS(q.conn)
+	}
+	for conn := range q.reverse {
+		closeWS(conn)
+	}
+	q.isConnected = false
+}
+
+// authHeader 返回携带访问凭据的请求头，未配置凭据时返回 nil
+func (q *QQBot) authHeader() http.Header {
+	if q.cfg.AccessToken == "" {
+		return nil
+	}
+	return http.Header{"Authorization": []string{"Bearer " + q.cfg.AccessToken}}
+}
+
+// authorized 校验 OneBot 连入请求携带的访问凭据
+// 兼容 "Bearer <token>"、"Token <token>" 请求头与 access_token 查询参数，未配置凭据时放行
+func (q *QQBot) authorized(r *http.Request) bool {
+	if q.cfg.AccessToken == "" {
+		return true
+	}
+	token := r.URL.Query().Get("access_token")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(auth, "Bearer "), "Token "))
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(q.cfg.AccessToken)) == 1
+}
+
+// closeWS 先尝试发送关闭帧，再断开底层连接
+func closeWS(conn *websocket.Conn) {
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// Endpoints 按连接模式提供反向 WebSocket 或 HTTP POST 上报的接入路由
+func (q *QQBot) Endpoints() map[string]http.Handler {
+	switch q.cfg.Mode {
+	case qqModeReverseWS:
+		return map[string]http.Handler{q.reversePath(): http.HandlerFunc(q.serveReverseWS)}
+	case qqModeHTTP:
+		return map[string]http.Handler{q.postPath(): http.HandlerFunc(q.servePost)}
+	}
+	return nil
+}
+
+// reversePath 返回反向 WebSocket 的挂载路径
+func (q *QQBot) reversePath() string {
+	if q.cfg.ReversePath != "" {
+		return q.cfg.ReversePath
+	}
+	return "/onebot/" + q.name + "/ws"
+}
+
+// postPath 返回 HTTP POST 事件上报的挂载路径
+func (q *QQBot) postPath() string {
+	if q.cfg.PostPath != "" {
+		return q.cfg.PostPath
+	}
+	return "/onebot/" + q.name + "/event"
+}
+
+// serveReverseWS 接受 OneBot 发起的反向 WebSocket 连接，多个账号可以连入同一路径
+// 连接需携带 X-Self-ID 头；X-Client-Role 为 Event 的连接只接收事件，
+// API 与 Universal 连接作为该账号调用动作的通道 (同一账号的新连接替换旧连接)
+func (q *QQBot) serveReverseWS(w http.ResponseWriter, r *http.Request) {
+	if !q.authorized(r) {
+		utils.Logger.Warn("QQ 反向 WebSocket 访问凭据无效", zap.String("实例", q.name), zap.String("来源", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	selfID := r.Header.Get("X-Self-ID")
+	if selfID == "" {
+		http.Error(w, "missing X-Self-ID", http.StatusBadRequest)
+		return
+	}
+	role := r.Header.Get("X-Client-Role")
+	if q.stopped() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := qqReverseUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		utils.Logger.Error("QQ 反向 WebSocket 握手失败", zap.Error(err))
+		return
+	}
+
+	q.mu.Lock()
+	q.reverse[conn] = true
+	if role != "Event" {
+		if old, ok := q.accounts[selfID]; ok {
+			closeWS(old)
+			delete(q.reverse, old)
+		}
+		q.accounts[selfID] = conn
+	}
+	q.mu.Unlock()
+	utils.Logger.Info("QQ OneBot 反向 WebSocket 已连入", zap.String("实例", q.name), zap.String("self_id", selfID), zap.String("role", role))
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		q.dispatch(message)
+	}
+
+	q.mu.Lock()
+	delete(q.reverse, conn)
+	if q.accounts[selfID] == conn {
+		delete(q.accounts, selfID)
+	}
+	q.mu.Unlock()
+	conn.Close()
+	if !q.stopped() {
+		utils.Logger.Warn("QQ OneBot 反向 WebSocket 已断开", zap.String("self_id", selfID))
 	}
 }
 
+// servePost 接收 OneBot 的 HTTP POST 事件上报，配置了 secret 时校验 X-Signature (HMAC-SHA1)
+func (q *QQBot) servePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if q.cfg.Secret != "" && !verifySignature(q.cfg.Secret, body, r.Header.Get("X-Signature")) {
+		utils.Logger.Warn("QQ 事件上报签名校验失败", zap.String("实例", q.name), zap.String("来源", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// 停机期间拒绝的事件以 503 应答，不向 OneBot 确认
+	if err := q.parseMessage(body); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	// 204 表示不使用快速操作，回复统一通过 HTTP API 发出
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySignature 校验 OneBot 上报的签名，格式为 "sha1=<HMAC-SHA1 十六进制摘要>"
+func verifySignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
 // OneBotEvent 映射 OneBot 11 上报的 JSON 原始字段
 type OneBotEvent struct {
-	PostType    string `json:"post_type"`    // 事件类型 (message, notice, request, meta_event)
-	MessageType string `json:"message_type"` // 消息子类型 (private, group)
-	SubType     string `json:"sub_type"`
-	UserId      int64  `json:"user_id"`     // 发送者 QQ 号
-	GroupId     int64  `json:"group_id"`    // 群组号
-	RawMessage  string `json:"raw_message"` // 原始文本消息，含 CQ 码
-	Sender      struct {
+	PostType    string   `json:"post_type"`    // 事件类型 (message, notice, request, meta_event)
+	MessageType string   `json:"message_type"` // 消息子类型 (private, group)
+	SubType     string   `json:"sub_type"`
+	SelfId      int64    `json:"self_id"`     // 接收事件的机器人 QQ 号
+	UserId      int64    `json:"user_id"`     // 发送者 QQ 号
+	GroupId     int64    `json:"group_id"`    // 群组号
+	MessageId   OneBotID `json:"message_id"`  // 消息 ID
+	RawMessage  string   `json:"raw_message"` // 原始文本消息，含 CQ 码
+	// Message 消息内容，按实现的上报格式为 CQ 码字符串或消息段数组
+	Message json.RawMessage `json:"message"`
+	Sender  struct {
 		Nickname string `json:"nickname"` // 发送者昵称
+		Role     string `json:"role"`     // 群内身份 (owner, admin, member)，仅群消息有效
 	} `json:"sender"`
 }
 
-// parseMessage 将接收到的 OneBot JSON 数据解析为内部统一结构
-func (q *QQBot) parseMessage(data []byte) {
+// parseMessage 将接收到的 OneBot JSON 数据解析为内部统一结构，返回回调拒绝事件时的错误
+func (q *QQBot) parseMessage(data []byte) error {
 	var event OneBotEvent
 	if err := json.Unmarshal(data, &event); err != nil {
-		return // 忽略控制包、Meta 数据或非格式化报文
+		return nil // 忽略控制包、Meta 数据或非格式化报文
 	}
 
 	// 仅监听消息上报
@@ -113,62 +379,536 @@ func (q *QQBot) parseMessage(data []byte) {
 			targetID = fmt.Sprintf("%d", event.GroupId)
 		}
 
+		// 记录接收消息的账号，回复需从同一账号发出
+		selfID := ""
+		if event.SelfId != 0 {
+			selfID = fmt.Sprintf("%d", event.SelfId)
+		}
+
+		// 解析消息段 (兼容字符串与数组格式)，失败时退回 raw_message
+		segments, err := ParseSegments(event.Message)
+		if err != nil || len(segments) == 0 {
+			segments = ParseCQ(event.RawMessage)
+		}
+
+		// 检测并移除 @机器人 的消息段，便于上层判断触发规则
+		mentioned := false
+		kept := segments[:0:0]
+		for _, seg := range segments {
+			if seg.Type == SegmentAt && q.isSelf(selfID, seg.Data["qq"]) {
+				mentioned = true
+				continue
+			}
+			kept = append(kept, seg)
+		}
+		content := PlainText(kept)
+		if content == "" {
+			return nil // 仅含 @ 或无法展示的消息
+		}
+
+		// 引用回复只携带原消息 ID，原消息内容在需要时通过 ResolveQuote 查询
+		var quote *QuotedMessage
+		for _, seg := range kept {
+			if seg.Type == SegmentReply && seg.Data["id"] != "" {
+				quote = &QuotedMessage{ID: seg.Data["id"]}
+				break
+			}
+		}
+
+		// 多个账号在同一群时每个连接都会上报同一条消息，仅处理最先到达的一份
+		if isGroup && !q.claimGroupMessage(targetID, string(event.MessageId)) {
+			return nil
+		}
+
 		// 调用上层管理逻辑的回调函数
-		q.handler(MessageEvent{
-			Platform:   "qq",
-			PlatformID: targetID,
-			UserID:     fmt.Sprintf("%d", event.UserId),
-			Username:   event.Sender.Nickname,
-			Content:    event.RawMessage,
-			MsgType:    MsgTypeText,
-			IsGroup:    isGroup,
+		return q.handler(MessageEvent{
+			Platform:    "qq",
+			Adapter:     q.name,
+			SelfID:      selfID,
+			PlatformID:  targetID,
+			MessageID:   string(event.MessageId),
+			UserID:      fmt.Sprintf("%d", event.UserId),
+			Username:    event.Sender.Nickname,
+			Content:     content,
+			MsgType:     segmentsMsgType(kept),
+			Segments:    kept,
+			IsGroup:     isGroup,
+			IsMentioned: mentioned,
+			IsAdmin:     event.Sender.Role == "owner" || event.Sender.Role == "admin",
+			Quote:       quote,
 		})
 	}
+	return nil
+}
+
+// ResolveQuote 通过 get_msg 查询事件引用的原消息 (由接收事件的账号调用)
+func (q *QQBot) ResolveQuote(event MessageEvent) (*QuotedMessage, error) {
+	if event.Quote == nil {
+		return nil, nil
+	}
+	msg, err := q.API(event.SelfID).GetMsg(event.Quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := ParseSegments(msg.Message)
+	if err != nil || len(segments) == 0 {
+		segments = ParseCQ(msg.RawMessage)
+	}
+	name := msg.Sender.Card
+	if name == "" {
+		name = msg.Sender.Nickname
+	}
+	return &QuotedMessage{
+		ID:       event.Quote.ID,
+		UserID:   string(msg.Sender.UserID),
+		Username: name,
+		Content:  PlainText(segments),
+		FromBot:  event.SelfID != "" && string(msg.Sender.UserID) == event.SelfID,
+	}, nil
 }
 
-// OneBotAction 定义发送给端点的 API 调用结构
-type OneBotAction struct {
-	Action string      `json:"action"` // 动作名称
-	Params interface{} `json:"params"` // 参数负载
-	Echo   string      `json:"echo"`   // 回显标识
+// SendMessage 向指定的目标发送 QQ 纯文本消息 (支持私聊和群聊)，返回 OneBot 分配的消息 ID
+// 内容始终作为文本消息段发送，其中的 CQ 码不会被执行；反向 WebSocket 模式下有多个账号在线时需通过 Account 指定发送账号
+func (q *QQBot) SendMessage(targetID string, content string, isGroup bool) (string, error) {
+	return q.sendTextAs("", targetID, content, isGroup)
 }
 
-// SendMessage 向指定的目标发送 QQ 消息 (支持私聊和群聊)
-func (q *QQBot) SendMessage(targetID string, content string, isGroup bool) error {
-	if !q.isConnected {
-		return fmt.Errorf("QQ 机器人当前未在线")
+// sendTextAs 通过指定账号发送纯文本消息
+func (q *QQBot) sendTextAs(selfID, targetID, content string, isGroup bool) (string, error) {
+	return q.sendAs(selfID, targetID, []MessageSegment{TextSegment(content)}, isGroup)
+}
+
+// auditCQInjection 检查待发送的文本是否包含可被解析的 CQ 码，若有则记录审计日志
+// 文本以消息段形式发出，这些 CQ 码只会原样显示，不会被 OneBot 执行
+func (q *QQBot) auditCQInjection(selfID, targetID, content string, isGroup bool) {
+	if !strings.Contains(content, "[CQ:") {
+		return
+	}
+	var codes []string
+	for _, seg := range ParseCQ(content) {
+		if seg.Type != SegmentText {
+			codes = append(codes, seg.Type)
+		}
+	}
+	if len(codes) == 0 {
+		return
+	}
+	utils.Logger.Warn("已拦截回复文本中的 CQ 码，按纯文本发送",
+		zap.String("实例", q.name),
+		zap.String("self_id", selfID),
+		zap.String("目标", targetID),
+		zap.Bool("群聊", isGroup),
+		zap.Strings("CQ 码类型", codes),
+		zap.String("片段", cqExcerpt(content)))
+}
+
+// cqAuditExcerptLength 审计日志中记录的内容片段长度（字符数）
+const cqAuditExcerptLength = 40
+
+// cqExcerpt 截取从首个 CQ 码开始的一小段内容，日志只需定位问题，不记录完整回复
+func cqExcerpt(content string) string {
+	if i := strings.Index(content, "[CQ:"); i > 0 {
+		content = content[i:]
+	}
+	runes := []rune(content)
+	if len(runes) > cqAuditExcerptLength {
+		runes = append(runes[:cqAuditExcerptLength], []rune("…")...)
+	}
+	return string(runes)
+}
+
+// sendAs 通过指定账号发送由消息段组成的 QQ 消息，selfID 为空时使用默认连接
+// 消息段以数组格式提交，只有程序显式构造的非文本消息段才会成为富媒体内容；文本段中疑似 CQ 码注入的内容会记录审计日志
+func (q *QQBot) sendAs(selfID, targetID string, segments []MessageSegment, isGroup bool) (string, error) {
+	for _, seg := range segments {
+		if seg.Type == SegmentText {
+			q.auditCQInjection(selfID, targetID, seg.Data["text"], isGroup)
+		}
 	}
 
 	action := "send_private_msg"
 	params := map[string]interface{}{
-		"user_id": targetID,
-		"message": content,
+		"user_id": idParam(targetID),
+		"message": segments,
 	}
 
 	// 如果是群聊则切换 API 动作为发送群消息
 	if isGroup {
 		action = "send_group_msg"
 		params = map[string]interface{}{
-			"group_id": targetID,
-			"message":  content,
+			"group_id": idParam(targetID),
+			"message":  segments,
 		}
 	}
 
-	payload := OneBotAction{
-		Action: action,
-		Params: params,
-		Echo:   fmt.Sprintf("send_%d", time.Now().UnixNano()), // 使用纳秒级时间确保 Echo 唯一
+	var result struct {
+		MessageID OneBotID `json:"message_id"`
 	}
+	if err := q.callAction(selfID, action, params, &result); err != nil {
+		return "", err
+	}
+	return string(result.MessageID), nil
+}
+
+// callAction 调用 OneBot 动作并将响应数据解析到 out (可为 nil)
+// http 模式通过 HTTP API，其余模式通过对应账号的 WebSocket 连接发出并按 echo 等待响应
+func (q *QQBot) callAction(selfID, action string, params interface{}, out interface{}) error {
+	var resp *OneBotResponse
+	var err error
+	if q.cfg.Mode == qqModeHTTP {
+		resp, err = q.postAction(action, params)
+	} else {
+		resp, err = q.wsAction(selfID, action, params)
+	}
+	if err != nil {
+		return err
+	}
+	return resp.decode(action, out)
+}
+
+// wsAction 通过 WebSocket 发出动作，并等待携带相同 echo 的响应，超时或停止时返回错误
+func (q *QQBot) wsAction(selfID, action string, params interface{}) (*OneBotResponse, error) {
+	echo := fmt.Sprintf("%s_%d", action, q.echoSeq.Add(1))
+	ch := make(chan *OneBotResponse, 1)
+	q.pendingMu.Lock()
+	q.pending[echo] = ch
+	q.pendingMu.Unlock()
+	defer func() {
+		q.pendingMu.Lock()
+		delete(q.pending, echo)
+		q.pendingMu.Unlock()
+	}()
+
+	q.mu.Lock()
+	conn, err := q.connFor(selfID)
+	if err == nil {
+		// 将 API 调用请求以 JSON 形式通过长连接发出
+		err = conn.WriteJSON(OneBotAction{Action: action, Params: params, Echo: echo})
+	}
+	q.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-time.After(qqActionTimeout):
+		return nil, fmt.Errorf("OneBot %s 调用超时", action)
+	case <-q.done:
+		return nil, fmt.Errorf("QQ 适配器已停止")
+	}
+}
+
+// dispatch 分发连接上收到的数据：带 echo 的动作响应交给等待中的调用，其余作为事件解析
+func (q *QQBot) dispatch(data []byte) {
+	var probe struct {
+		PostType string `json:"post_type"`
+		Echo     string `json:"echo"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return // 忽略无法识别的报文
+	}
+	if probe.PostType != "" || probe.Echo == "" {
+		// WebSocket 上报无法要求重投，停机期间被拒绝的事件只能丢弃
+		q.parseMessage(data)
+		return
+	}
+
+	var resp OneBotResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return
+	}
+	q.pendingMu.Lock()
+	ch, ok := q.pending[probe.Echo]
+	q.pendingMu.Unlock()
+	if ok {
+		ch <- &resp // 通道带缓冲且每个 echo 只对应一次调用，不会阻塞
+	}
+}
+
+// qqSeenTTL 群消息去重记录的保留时长，覆盖各账号上报同一条消息的时间差
+const qqSeenTTL = time.Minute
+
+// claimGroupMessage 登记一条群消息，同一群内相同消息 ID 在保留时长内已登记过时返回 false
+func (q *QQBot) claimGroupMessage(groupID, messageID string) bool {
+	if messageID == "" {
+		return true
+	}
+	now := time.Now()
+	key := groupID + "|" + messageID
+
+	q.seenMu.Lock()
+	defer q.seenMu.Unlock()
+	for k, at := range q.seen {
+		if now.Sub(at) > qqSeenTTL {
+			delete(q.seen, k)
+		}
+	}
+	if _, ok := q.seen[key]; ok {
+		return false
+	}
+	q.seen[key] = now
+	return true
+}
 
+// isSelf 判断 QQ 号是否为本适配器接入的账号，@任一在线账号都视为 @机器人，
+// 这样群消息无论由哪个账号先上报，触发判断都一致
+func (q *QQBot) isSelf(selfID, qq string) bool {
+	if qq == "" {
+		return false
+	}
+	if qq == selfID {
+		return true
+	}
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	// 将 API 调用请求以 JSON 形式通过长连接发出
-	return q.conn.WriteJSON(payload)
+	_, ok := q.accounts[qq]
+	return ok
+}
+
+// connFor 返回调用动作使用的 WebSocket 连接，调用方需持有 q.mu
+// 正向模式只有一条连接；反向模式按 self_id 选择账号，未指定账号时使用 QQ 号最小的在线账号
+func (q *QQBot) connFor(selfID string) (*websocket.Conn, error) {
+	if q.cfg.Mode == qqModeForwardWS {
+		if !q.isConnected || q.conn == nil {
+			return nil, fmt.Errorf("QQ 机器人当前未在线")
+		}
+		return q.conn, nil
+	}
+
+	if selfID != "" {
+		if conn, ok := q.accounts[selfID]; ok {
+			return conn, nil
+		}
+		return nil, fmt.Errorf("QQ 账号 %s 当前未在线", selfID)
+	}
+	if id := q.defaultAccount(); id != "" {
+		return q.accounts[id], nil
+	}
+	return nil, fmt.Errorf("QQ 机器人当前未在线")
+}
+
+// defaultAccount 返回未指定发送账号时使用的默认账号，调用方需持有 q.mu
+// 按 QQ 号数值取最小者，保证多账号在线时选择结果与连接顺序无关
+func (q *QQBot) defaultAccount() string {
+	selected := ""
+	for id := range q.accounts {
+		if selected == "" || len(id) < len(selected) || (len(id) == len(selected) && id < selected) {
+			selected = id
+		}
+	}
+	return selected
+}
+
+// postAction 通过 OneBot HTTP API 调用动作并返回响应
+func (q *QQBot) postAction(action string, params interface{}) (*OneBotResponse, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := strings.TrimRight(q.cfg.HTTPURL, "/") + "/" + action
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.cfg.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+q.cfg.AccessToken)
+	}
+
+	resp, err := q.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OneBot %s 调用失败 (HTTP %d)", action, resp.StatusCode)
+	}
+
+	var result OneBotResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("OneBot %s 响应解析失败: %w", action, err)
+	}
+	return &result, nil
 }
 
-// SendImage 利用 CQ 码发送富媒体图片消息
+// SendImage 利用图片消息段发送富媒体图片消息
 func (q *QQBot) SendImage(targetID string, imageUrl string, isGroup bool) error {
-	// 构建 OneBot 规范的图片 CQ 码负载
-	cqCode := fmt.Sprintf("[CQ:image,file=%s]", imageUrl)
-	return q.SendMessage(targetID, cqCode, isGroup)
+	_, err := q.sendAs("", targetID, []MessageSegment{ImageSegment(imageUrl)}, isGroup)
+	return err
+}
+
+// SendRich 将富消息渲染为 OneBot 消息段发送
+func (q *QQBot) SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error) {
+	return q.sendAs("", targetID, oneBotSegments(msg), isGroup)
+}
+
+// oneBotSegments 将富消息渲染为 OneBot 消息段，引用回复固定放在首位 (部分实现要求)
+// 二进制图片与文件以 base64:// 形式内联；文件段并非 OneBot 11 标准消息段，需 OneBot 实现支持 (如 NapCat、Lagrange)
+func oneBotSegments(msg OutboundMessage) []MessageSegment {
+	var segments []MessageSegment
+	if id := msg.ReplyTarget(); id != "" {
+		segments = append(segments, ReplySegment(id))
+	}
+	for _, seg := range msg.Segments {
+		switch seg.Type {
+		case OutboundText:
+			if seg.Text != "" {
+				segments = append(segments, TextSegment(seg.Text))
+			}
+		case OutboundMention:
+			segments = append(segments, AtSegment(seg.UserID))
+		case OutboundImage:
+			segments = append(segments, ImageSegment(seg.source()))
+		case OutboundFile:
+			segments = append(segments, MessageSegment{
+				Type: "file",
+				Data: map[string]string{"file": seg.source(), "name": seg.fileName()},
+			})
+		case OutboundEmbed:
+			segments = append(segments, TextSegment(seg.Embed.Text()))
+		}
+	}
+	return segments
+}
+
+// BeginStream 开启流式回复：QQ 不支持编辑消息，因此按段落逐条发送已完成的内容
+func (q *QQBot) BeginStream(targetID string, isGroup bool, lead []OutboundSegment) (ReplyStream, error) {
+	return q.beginStreamAs("", targetID, isGroup, lead), nil
+}
+
+// beginStreamAs 开启一次由指定账号发送的流式回复
+func (q *QQBot) beginStreamAs(selfID, targetID string, isGroup bool, lead []OutboundSegment) ReplyStream {
+	return &qqStream{bot: q, selfID: selfID, targetID: targetID, isGroup: isGroup, lead: lead, limit: q.cfg.MaxLength}
+}
+
+// Account 返回绑定到指定 QQ 账号的发送视图，回复经由该账号的连接发出
+func (q *QQBot) Account(selfID string) BotAdapter {
+	return &qqAccount{bot: q, selfID: selfID}
+}
+
+// qqAccount 绑定到单个 QQ 账号的发送视图，生命周期由所属的 QQBot 管理
+type qqAccount struct {
+	bot    *QQBot
+	selfID string
+}
+
+// Start 发送视图没有独立的连接，无需启动
+func (a *qqAccount) Start() error { return nil }
+
+// Stop 发送视图没有独立的连接，无需停止
+func (a *qqAccount) Stop() {}
+
+// SendMessage 通过绑定的账号发送 QQ 纯文本消息
+func (a *qqAccount) SendMessage(targetID string, content string, isGroup bool) (string, error) {
+	return a.bot.sendTextAs(a.selfID, targetID, content, isGroup)
+}
+
+// SendImage 通过绑定的账号发送图片
+func (a *qqAccount) SendImage(targetID string, imageUrl string, isGroup bool) error {
+	_, err := a.bot.sendAs(a.selfID, targetID, []MessageSegment{ImageSegment(imageUrl)}, isGroup)
+	return err
+}
+
+// SendRich 通过绑定的账号发送富消息
+func (a *qqAccount) SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error) {
+	return a.bot.sendAs(a.selfID, targetID, oneBotSegments(msg), isGroup)
+}
+
+// ResolveQuote 查询事件引用的原消息
+func (a *qqAccount) ResolveQuote(event MessageEvent) (*QuotedMessage, error) {
+	return a.bot.ResolveQuote(event)
+}
+
+// BeginStream 开启一次由绑定账号发送的流式回复
+func (a *qqAccount) BeginStream(targetID string, isGroup bool, lead []OutboundSegment) (ReplyStream, error) {
+	return a.bot.beginStreamAs(a.selfID, targetID, isGroup, lead), nil
+}
+
+// qqStream 以空行分隔的段落为单位渐进投递回复
+type qqStream struct {
+	bot      *QQBot
+	selfID   string // 发送账号，为空时使用默认连接
+	targetID string
+	isGroup  bool
+	lead     []OutboundSegment // 附加在首条消息上的引用回复或 @
+	limit    int               // 单条消息的字符上限，超长段落会继续切分
+	sentLen  int               // 已切分入队部分在完整文本中的字节偏移
+	queued   []string          // 已切分但尚未发出的片段，发送失败时下次从失败的片段继续
+	sent     []SentMessage     // 已发出的各条消息
+}
+
+// Update 发送所有已完整生成的段落，处于未闭合代码块内的空行不作为段落边界
+// 上次发送失败时先补发剩余的片段，已成功发出的片段不会重复发送
+func (s *qqStream) Update(text string) error {
+	for {
+		if len(s.queued) == 0 {
+			boundary := s.nextBoundary(text)
+			if boundary < 0 {
+				return nil
+			}
+			s.enqueue(text[s.sentLen:boundary])
+			s.sentLen = boundary + 2
+		}
+		if err := s.send(); err != nil {
+			return err
+		}
+	}
+}
+
+// nextBoundary 查找未投递部分中第一个位于代码块之外的段落分隔 (空行) 位置，不存在时返回 -1
+func (s *qqStream) nextBoundary(text string) int {
+	offset := s.sentLen
+	for {
+		idx := strings.Index(text[offset:], "\n\n")
+		if idx < 0 {
+			return -1
+		}
+		boundary := offset + idx
+		if strings.Count(text[:boundary], "```")%2 == 0 {
+			return boundary
+		}
+		offset = boundary + 2
+	}
+}
+
+// Finish 发送剩余的全部内容 (含此前发送失败的片段)，返回实际发出的各条消息
+func (s *qqStream) Finish(text string) ([]SentMessage, error) {
+	if s.sentLen < len(text) {
+		s.enqueue(text[s.sentLen:])
+		s.sentLen = len(text)
+	}
+	err := s.send()
+	return s.sent, err
+}
+
+// enqueue 将段落按长度上限切分为多个片段加入待发队列，空白段落直接跳过
+func (s *qqStream) enqueue(paragraph string) {
+	s.queued = append(s.queued, SplitMessage(paragraph, s.limit)...)
+}
+
+// send 依次发送待发队列中的片段，每成功一条即出队；失败时保留该片段及其后的片段
+func (s *qqStream) send() error {
+	for i := 0; len(s.queued) > 0; i++ {
+		chunk := s.queued[0]
+		if i > 0 {
+			RandomDelay(chunkDelay(chunk))
+		}
+		var id string
+		var err error
+		if len(s.sent) == 0 && len(s.lead) > 0 {
+			msg := NewOutboundMessage(append(append([]OutboundSegment{}, s.lead...), Text(chunk))...)
+			id, err = s.bot.sendAs(s.selfID, s.targetID, oneBotSegments(msg), s.isGroup)
+		} else {
+			id, err = s.bot.sendTextAs(s.selfID, s.targetID, chunk, s.isGroup)
+		}
+		if err != nil {
+			return err
+		}
+		s.sent = append(s.sent, SentMessage{ID: id, Content: chunk})
+		s.queued = s.queued[1:]
+	}
+	return nil
 }
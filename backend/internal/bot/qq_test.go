@@ -0,0 +1,257 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sk-im-bot/internal/config"
+	"sk-im-bot/pkg/utils"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// oneBotRecorder 模拟 OneBot HTTP API，记录各次发送的消息段，fail 返回 true 的调用以失败响应
+type oneBotRecorder struct {
+	mu    sync.Mutex
+	calls [][]MessageSegment
+	fail  func(call int) bool
+}
+
+func (r *oneBotRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var params struct {
+		Message []MessageSegment `json:"message"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	call := len(r.calls)
+	r.calls = append(r.calls, params.Message)
+	r.mu.Unlock()
+	if r.fail != nil && r.fail(call) {
+		w.Write([]byte(`{"status":"failed","retcode":100,"message":"发送失败"}`))
+		return
+	}
+	w.Write([]byte(`{"status":"ok","retcode":0,"data":{"message_id":1}}`))
+}
+
+// newQQTestBot 构造 http 模式的 QQ 实例，动作调用由 oneBotRecorder 接收
+func newQQTestBot(t *testing.T) (*QQBot, *oneBotRecorder) {
+	t.Helper()
+	recorder := &oneBotRecorder{}
+	server := httptest.NewServer(recorder)
+	t.Cleanup(server.Close)
+	bot := &QQBot{
+		name:       "test",
+		cfg:        config.QQConfig{Mode: qqModeHTTP, HTTPURL: server.URL},
+		httpClient: server.Client(),
+		seen:       make(map[string]time.Time),
+	}
+	return bot, recorder
+}
+
+// observeLogs 将全局日志替换为内存记录器，测试结束后恢复
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zapcore.WarnLevel)
+	previous := utils.Logger
+	utils.Logger = zap.New(core)
+	t.Cleanup(func() { utils.Logger = previous })
+	return logs
+}
+
+func TestQQSendPathsAuditCQInjection(t *testing.T) {
+	const injected = "好的 [CQ:at,qq=all]"
+	tests := []struct {
+		name string
+		send func(q *QQBot) error
+	}{
+		{"SendMessage", func(q *QQBot) error {
+			_, err := q.SendMessage("10001", injected, true)
+			return err
+		}},
+		{"SendRich", func(q *QQBot) error {
+			_, err := q.SendRich("10001", NewOutboundMessage(ReplyTo("7"), Text(injected)), true)
+			return err
+		}},
+		{"流式回复首条", func(q *QQBot) error {
+			stream := q.beginStreamAs("", "10001", true, []OutboundSegment{Mention("42", "")})
+			_, err := stream.Finish(injected)
+			return err
+		}},
+		{"流式回复后续段落", func(q *QQBot) error {
+			stream := q.beginStreamAs("", "10001", true, nil)
+			_, err := stream.Finish("第一段\n\n" + injected)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, recorder := newQQTestBot(t)
+			logs := observeLogs(t)
+			if err := tt.send(q); err != nil {
+				t.Fatal(err)
+			}
+			if n := logs.FilterMessageSnippet("CQ 码").Len(); n != 1 {
+				t.Errorf("CQ 码审计日志 = %d 条, want 1", n)
+			}
+			for _, call := range recorder.calls {
+				for _, seg := range call {
+					if seg.Type == "at" && seg.Data["qq"] == "all" {
+						t.Errorf("文本中的 CQ 码被解析为消息段: %+v", seg)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestQQServePostRefusedEventReturns503(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(MessageEvent) error
+		want    int
+	}{
+		{"已接收", func(MessageEvent) error { return nil }, http.StatusNoContent},
+		{"停机中拒绝", func(MessageEvent) error { return ErrShuttingDown }, http.StatusServiceUnavailable},
+	}
+	body := `{"post_type":"message","message_type":"private","self_id":1,"user_id":2,"message_id":3,"message":"hi","raw_message":"hi"}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &QQBot{name: "test", handler: tt.handler}
+			w := httptest.NewRecorder()
+			q.servePost(w, httptest.NewRequest(http.MethodPost, "/onebot", strings.NewReader(body)))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestQQStreamResumesAfterPartialParagraphFailure(t *testing.T) {
+	q, recorder := newQQTestBot(t)
+	observeLogs(t)
+	q.cfg.MaxLength = 5
+	recorder.fail = func(call int) bool { return call == 1 }
+	stream := q.beginStreamAs("", "10001", false, nil)
+
+	// 段落切分为两条，第二条发送失败
+	text := "第一段内容\n第二段内容\n\n"
+	if err := stream.Update(text); err == nil {
+		t.Fatal("Update() error = nil, want the failed chunk's error")
+	}
+	// 再次投递时只补发失败的片段
+	if err := stream.Update(text); err != nil {
+		t.Fatal(err)
+	}
+	sent, err := stream.Finish(text + "尾声")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	for _, call := range recorder.calls {
+		texts = append(texts, PlainText(call))
+	}
+	if want := []string{"第一段内容", "第二段内容", "第二段内容", "尾声"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("OneBot calls = %q, want %q", texts, want)
+	}
+	var contents []string
+	for _, m := range sent {
+		contents = append(contents, m.Content)
+	}
+	if want := []string{"第一段内容", "第二段内容", "尾声"}; !reflect.DeepEqual(contents, want) {
+		t.Errorf("sent = %q, want %q", contents, want)
+	}
+}
+
+func TestQQGroupMessageDeliveredByTwoAccountsHandledOnce(t *testing.T) {
+	var events []MessageEvent
+	q := NewQQBot("test", config.QQConfig{Mode: qqModeReverseWS}, func(e MessageEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	q.accounts["1001"] = &websocket.Conn{}
+	q.accounts["1002"] = &websocket.Conn{}
+
+	// 两个账号各自上报同一条 @1002 的群消息，1001 的连接先到达
+	for _, self := range []int{1001, 1002} {
+		body := fmt.Sprintf(`{"post_type":"message","message_type":"group","self_id":%d,"group_id":500,"user_id":7,"message_id":42,`+
+			`"message":[{"type":"at","data":{"qq":"1002"}},{"type":"text","data":{"text":" 你好"}}]}`, self)
+		if err := q.parseMessage([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 另一条消息不受影响
+	if err := q.parseMessage([]byte(`{"post_type":"message","message_type":"group","self_id":1002,"group_id":500,"user_id":7,"message_id":43,"message":"再见"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("handled %d events, want 2", len(events))
+	}
+	if e := events[0]; e.SelfID != "1001" || !e.IsMentioned || e.Content != "你好" {
+		t.Errorf("first event = {SelfID:%q IsMentioned:%v Content:%q}, want reply from 1001 with the mention of 1002 detected", e.SelfID, e.IsMentioned, e.Content)
+	}
+	if events[1].MessageID != "43" {
+		t.Errorf("second event MessageID = %q, want 43", events[1].MessageID)
+	}
+}
+
+func TestQQConnForDefaultsToLowestAccount(t *testing.T) {
+	q := NewQQBot("test", config.QQConfig{Mode: qqModeReverseWS}, nil)
+	if _, err := q.connFor(""); err == nil {
+		t.Error("connFor() with no account online: error = nil")
+	}
+
+	low, high := &websocket.Conn{}, &websocket.Conn{}
+	q.accounts["900000"] = low
+	q.accounts["10001"] = high
+	q.accounts["20002"] = &websocket.Conn{}
+	for i := 0; i < 10; i++ {
+		conn, err := q.connFor("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn != high {
+			t.Fatal("connFor(\"\") did not pick the lowest QQ number 10001")
+		}
+	}
+	if conn, err := q.connFor("900000"); err != nil || conn != low {
+		t.Errorf("connFor(900000) = %p, %v; want the named account", conn, err)
+	}
+}
+
+func TestQQAuditCQInjectionLogsExcerptOnly(t *testing.T) {
+	logs := observeLogs(t)
+	q := &QQBot{name: "test"}
+	secret := strings.Repeat("私密", 50)
+	q.auditCQInjection("1", "10001", "前文"+secret+"[CQ:image,file=x.png]"+secret, true)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if _, ok := fields["内容"]; ok {
+		t.Error("audit log still records the full content")
+	}
+	excerpt, _ := fields["片段"].(string)
+	if !strings.HasPrefix(excerpt, "[CQ:image") || runeLen(excerpt) > cqAuditExcerptLength+1 {
+		t.Errorf("片段 = %q, want a short excerpt starting at the CQ code", excerpt)
+	}
+	if codes, _ := fields["CQ 码类型"].([]interface{}); len(codes) != 1 || codes[0] != "image" {
+		t.Errorf("CQ 码类型 = %v, want [image]", fields["CQ 码类型"])
+	}
+}
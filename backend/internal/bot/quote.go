@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// quoteThreadDepth 沿引用链向上回溯的最大消息数
+const quoteThreadDepth = 8
+
+// quoteHintLength 当前消息前附加的引用摘要的最大字符数
+const quoteHintLength = 60
+
+// quoteID 返回事件引用的平台消息 ID，未引用时为空
+func (e MessageEvent) quoteID() string {
+	if e.Quote == nil {
+		return ""
+	}
+	return e.Quote.ID
+}
+
+// resolveQuote 事件只携带被引用消息的 ID 时，通过适配器查询原消息内容；查询失败不影响正常回复
+func (m *BotManager) resolveQuote(event MessageEvent) MessageEvent {
+	if event.Quote == nil || event.Quote.Content != "" {
+		return event
+	}
+	resolver, ok := m.senderFor(event).(QuoteResolver)
+	if !ok {
+		return event
+	}
+	quote, err := resolver.ResolveQuote(event)
+	if err != nil {
+		utils.Logger.Warn("查询被引用的消息失败", zap.String("实例", event.Adapter), zap.String("消息", event.Quote.ID), zap.Error(err))
+		return event
+	}
+	event.Quote = quote
+	return event
+}
+
+// quotedThread 从数据库还原被引用消息所在的引用链，按时间正序返回
+// 机器人的回复可能分段发送，引用其中任意一段时同一次回复的全部分段一并纳入
+func (m *BotManager) quotedThread(session *model.Session, quoteID string) []model.Message {
+	if session == nil || quoteID == "" {
+		return nil
+	}
+
+	var thread []model.Message
+	seen := make(map[uint]bool)
+	for id := quoteID; id != "" && len(thread) < quoteThreadDepth; {
+		var found []model.Message
+		if err := model.DB.Where("session_id = ? AND platform_msg_id = ?", session.ID, id).
+			Order("id").Limit(1).Find(&found).Error; err != nil {
+			utils.Logger.Error("加载引用消息失败", zap.Uint("session_id", session.ID), zap.Error(err))
+			break
+		}
+		if len(found) == 0 {
+			break
+		}
+		msg := found[0]
+
+		group := []model.Message{msg}
+		if msg.Sender == botSender && msg.ReplyToMsgID != "" {
+			var chunks []model.Message
+			err := model.DB.Where("session_id = ? AND sender = ? AND reply_to_msg_id = ?", session.ID, botSender, msg.ReplyToMsgID).
+				Order("id").Find(&chunks).Error
+			if err == nil && len(chunks) > 0 {
+				group = chunks
+			}
+		}
+		for _, g := range group {
+			if !seen[g.ID] {
+				seen[g.ID] = true
+				thread = append(thread, g)
+			}
+		}
+		id = msg.ReplyToMsgID
+	}
+
+	sort.Slice(thread, func(i, j int) bool { return thread[i].ID < thread[j].ID })
+	return thread
+}
+
+// quoteContext 返回需补充到对话上下文中的引用链消息 (时间正序)，已在近期历史中的消息不重复加入
+// 数据库中找不到原消息 (如已被清理或来自机器人加入之前) 时，退回使用平台提供的原消息内容
+func (m *BotManager) quoteContext(session *model.Session, event MessageEvent, inHistory map[uint]bool) []openai.ChatCompletionMessage {
+	if event.Quote == nil {
+		return nil
+	}
+
+	thread := m.quotedThread(session, event.Quote.ID)
+	if len(thread) == 0 {
+		if event.Quote.Content == "" {
+			return nil
+		}
+		if event.Quote.FromBot {
+			return []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleAssistant, Content: event.Quote.Content}}
+		}
+		return []openai.ChatCompletionMessage{{
+			Role:    openai.ChatMessageRoleUser,
+			Content: speakerContent(event.Quote.Username, event.Quote.Content, event.IsGroup),
+		}}
+	}
+
+	var messages []openai.ChatCompletionMessage
+	for _, msg := range thread {
+		if inHistory[msg.ID] || msg.MsgType == string(MsgTypeCommand) {
+			continue
+		}
+		messages = append(messages, historyMessage(msg, event.IsGroup))
+	}
+	return messages
+}
+
+// quoteHint 返回附加在当前消息前的引用摘要，使模型知道用户针对的是哪条消息
+func quoteHint(quote *QuotedMessage) string {
+	if quote == nil || quote.Content == "" {
+		return ""
+	}
+	name := quote.Username
+	if quote.FromBot {
+		name = "你"
+	}
+	content := []rune(quote.Content)
+	if len(content) > quoteHintLength {
+		content = append(content[:quoteHintLength], []rune("…")...)
+	}
+	if name == "" {
+		return fmt.Sprintf("[引用: %s]\n", string(content))
+	}
+	return fmt.Sprintf("[引用 %s: %s]\n", name, string(content))
+}
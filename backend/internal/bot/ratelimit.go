@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"sk-im-bot/internal/config"
+)
+
+// 限流维度
+const (
+	ScopeUser     = "user"     // 按用户
+	ScopeGroup    = "group"    // 按群组/频道
+	ScopePlatform = "platform" // 按平台
+)
+
+// bucketIdleTTL 令牌桶闲置超过该时长且已回满时会被回收，避免内存无限增长
+const bucketIdleTTL = 10 * time.Minute
+
+// tokenBucket 单个限流键的令牌桶状态
+type tokenBucket struct {
+	tokens float64   // 当前剩余令牌数
+	last   time.Time // 上次补充令牌的时间
+}
+
+// bucketRule 单个维度的令牌桶参数
+type bucketRule struct {
+	perSecond float64 // 每秒补充的令牌数
+	burst     float64 // 桶容量
+}
+
+// RateLimitStats 限流计数器快照，供管理后台展示
+type RateLimitStats struct {
+	Enabled       bool              `json:"enabled"`        // 是否启用限流
+	Allowed       uint64            `json:"allowed"`        // 放行的回复次数
+	Limited       map[string]uint64 `json:"limited"`        // 各维度拦截的次数
+	NoticesSent   uint64            `json:"notices_sent"`   // 已发送的限流提示次数
+	ActiveBuckets int               `json:"active_buckets"` // 当前内存中的令牌桶数量
+}
+
+// RateLimiter 按用户、群组、平台三个维度进行令牌桶限流
+type RateLimiter struct {
+	mu             sync.Mutex
+	cfg            config.RateLimitConfig
+	rules          map[string]bucketRule
+	buckets        map[string]*tokenBucket
+	notices        map[string]time.Time // 用户上次收到限流提示的时间
+	noticeCooldown time.Duration
+	lastSweep      time.Time
+	stats          RateLimitStats
+}
+
+// NewRateLimiter 根据配置创建限流器，速率为 0 的维度不做限制
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	r := &RateLimiter{
+		cfg:       cfg,
+		rules:     make(map[string]bucketRule),
+		buckets:   make(map[string]*tokenBucket),
+		notices:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+		stats:     RateLimitStats{Enabled: cfg.Enabled, Limited: make(map[string]uint64)},
+	}
+	r.addRule(ScopeUser, cfg.UserRate, cfg.UserBurst)
+	r.addRule(ScopeGroup, cfg.GroupRate, cfg.GroupBurst)
+	r.addRule(ScopePlatform, cfg.PlatformRate, cfg.PlatformBurst)
+	if d, err := time.ParseDuration(cfg.NoticeCooldown); err == nil {
+		r.noticeCooldown = d
+	}
+	return r
+}
+
+// addRule 注册单个维度的规则，容量未配置时至少允许一次请求
+func (r *RateLimiter) addRule(scope string, perMinute float64, burst int) {
+	if perMinute <= 0 {
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	r.rules[scope] = bucketRule{perSecond: perMinute / 60, burst: float64(burst)}
+}
+
+// limitKeys 返回事件在各维度下的限流键，私聊不参与群组维度
+func limitKeys(event MessageEvent) map[string]string {
+	keys := map[string]string{
+		ScopeUser:     event.Platform + ":" + event.UserID,
+		ScopePlatform: event.Platform,
+	}
+	if event.IsGroup {
+		keys[ScopeGroup] = event.Platform + ":" + event.PlatformID
+	}
+	return keys
+}
+
+// Allow 判断事件是否允许触发一次 LLM 回复
+// 只有所有维度都有余量时才会同时扣减令牌，被拦截时返回触发限流的维度
+func (r *RateLimiter) Allow(event MessageEvent) (string, bool) {
+	if !r.cfg.Enabled {
+		return "", true
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(now)
+
+	keys := limitKeys(event)
+	pending := make([]*tokenBucket, 0, len(keys))
+	for _, scope := range []string{ScopeUser, ScopeGroup, ScopePlatform} {
+		rule, ok := r.rules[scope]
+		key, hasKey := keys[scope]
+		if !ok || !hasKey {
+			continue
+		}
+		bucket := r.refill(scope+"|"+key, rule, now)
+		if bucket.tokens < 1 {
+			r.stats.Limited[scope]++
+			return scope, false
+		}
+		pending = append(pending, bucket)
+	}
+
+	for _, bucket := range pending {
+		bucket.tokens--
+	}
+	r.stats.Allowed++
+	return "", true
+}
+
+// refill 获取 (或创建) 令牌桶并按流逝时间补充令牌
+func (r *RateLimiter) refill(key string, rule bucketRule, now time.Time) *tokenBucket {
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rule.burst, last: now}
+		r.buckets[key] = bucket
+		return bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rule.perSecond
+	if bucket.tokens > rule.burst {
+		bucket.tokens = rule.burst
+	}
+	bucket.last = now
+	return bucket
+}
+
+// sweep 定期回收长时间闲置的令牌桶与过期的提示记录 (调用方需持有锁)
+func (r *RateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < bucketIdleTTL {
+		return
+	}
+	r.lastSweep = now
+	for key, bucket := range r.buckets {
+		scope, _, _ := strings.Cut(key, "|")
+		rule := r.rules[scope]
+		// 仅回收已经回满的桶，回收后重新创建的桶状态与原来一致
+		full := bucket.tokens+now.Sub(bucket.last).Seconds()*rule.perSecond >= rule.burst
+		if full && now.Sub(bucket.last) > bucketIdleTTL {
+			delete(r.buckets, key)
+		}
+	}
+	for key, at := range r.notices {
+		if now.Sub(at) > r.noticeCooldown {
+			delete(r.notices, key)
+		}
+	}
+}
+
+// ShouldNotice 判断是否需要向被限流的用户发送提示，同一用户在冷却期内只提示一次
+func (r *RateLimiter) ShouldNotice(event MessageEvent) bool {
+	if r.cfg.Notice == "" {
+		return false
+	}
+	key := event.Platform + ":" + event.UserID
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.notices[key]; ok && now.Sub(last) < r.noticeCooldown {
+		return false
+	}
+	r.notices[key] = now
+	r.stats.NoticesSent++
+	return true
+}
+
+// Notice 返回配置的限流提示语
+func (r *RateLimiter) Notice() string {
+	return r.cfg.Notice
+}
+
+// Stats 返回当前限流计数器的快照
+func (r *RateLimiter) Stats() RateLimitStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.Limited = make(map[string]uint64, len(r.stats.Limited))
+	for scope, count := range r.stats.Limited {
+		stats.Limited[scope] = count
+	}
+	stats.ActiveBuckets = len(r.buckets)
+	return stats
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"sk-im-bot/internal/config"
+)
+
+// AdapterFactory 解析实例配置并创建适配器，同时返回该实例的通用配置 (触发规则、主人、长度上限等)
+// name 为实例名，适配器产生的事件需通过 MessageEvent.Adapter 携带该名称，回复才能路由回同一实例
+type AdapterFactory func(name string, settings map[string]interface{}, handler func(MessageEvent) error) (BotAdapter, config.AdapterConfig, error)
+
+// AdapterType 一类平台适配器的注册信息
+type AdapterType struct {
+	Name    string         // 类型名，同时作为该类适配器事件的 Platform 标识
+	Section string         // 顶层配置段名 (选填)，启用时自动创建与类型同名的实例
+	Factory AdapterFactory // 实例工厂
+}
+
+var (
+	adapterTypesMu sync.RWMutex
+	adapterTypes   = make(map[string]AdapterType)
+)
+
+// RegisterAdapterType 注册一类平台适配器，通常在适配器所在文件的 init 中调用
+// 重复注册同名类型会 panic，以便在启动阶段暴露冲突
+func RegisterAdapterType(t AdapterType) {
+	adapterTypesMu.Lock()
+	defer adapterTypesMu.Unlock()
+	if _, ok := adapterTypes[t.Name]; ok {
+		panic(fmt.Sprintf("适配器类型 %s 重复注册", t.Name))
+	}
+	adapterTypes[t.Name] = t
+}
+
+// lookupAdapterType 按类型名查找已注册的适配器类型
+func lookupAdapterType(name string) (AdapterType, bool) {
+	adapterTypesMu.RLock()
+	defer adapterTypesMu.RUnlock()
+	t, ok := adapterTypes[name]
+	return t, ok
+}
+
+// registeredAdapterTypes 按名称顺序返回全部已注册的适配器类型
+func registeredAdapterTypes() []AdapterType {
+	adapterTypesMu.RLock()
+	defer adapterTypesMu.RUnlock()
+	types := make([]AdapterType, 0, len(adapterTypes))
+	for _, t := range adapterTypes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// adapterInstance 运行中的适配器实例及其聊天策略
+type adapterInstance struct {
+	name      string             // 实例名
+	platform  string             // 所属平台 (适配器类型名)
+	adapter   BotAdapter         // 适配器实现
+	trigger   *TriggerPolicy     // 群聊回复触发规则
+	owners    map[string]bool    // 机器人主人用户 ID 集合
+	maxLength int                // 单条消息的字符数上限，超出时分段发送
+	reply     config.ReplyConfig // 群聊回复的引用方式
+}
+
+// instanceSpec 待创建的实例声明：来自顶层配置段或 adapters 列表
+type instanceSpec struct {
+	name     string
+	typ      string
+	settings map[string]interface{}
+}
+
+// collectInstanceSpecs 汇总配置中声明的全部适配器实例
+// 注册了配置段的类型先以类型名创建实例 (兼容旧的 qq/discord 配置)，随后追加 adapters 列表中的实例；
+// 列表中的实例未显式设置 enabled 时视为启用
+func collectInstanceSpecs(cfg *config.Config) []instanceSpec {
+	var specs []instanceSpec
+	for _, t := range registeredAdapterTypes() {
+		if t.Section == "" {
+			continue
+		}
+		if section := cfg.Section(t.Section); section != nil {
+			specs = append(specs, instanceSpec{name: t.Name, typ: t.Name, settings: section})
+		}
+	}
+	for _, inst := range cfg.Adapters {
+		settings := make(map[string]interface{}, len(inst.Settings)+1)
+		for k, v := range inst.Settings {
+			settings[k] = v
+		}
+		if _, ok := settings["enabled"]; !ok {
+			settings["enabled"] = true
+		}
+		specs = append(specs, instanceSpec{name: inst.Name, typ: inst.Type, settings: settings})
+	}
+	return specs
+}
+
+// buildInstance 按声明创建适配器实例，实例未启用时返回 nil
+func buildInstance(spec instanceSpec, handler func(MessageEvent) error) (*adapterInstance, error) {
+	if spec.name == "" {
+		return nil, fmt.Errorf("适配器实例缺少名称 (类型 %s)", spec.typ)
+	}
+	t, ok := lookupAdapterType(spec.typ)
+	if !ok {
+		return nil, fmt.Errorf("未知的适配器类型 %q", spec.typ)
+	}
+	adapter, common, err := t.Factory(spec.name, spec.settings, handler)
+	if err != nil {
+		return nil, fmt.Errorf("创建适配器实例 %s 失败: %w", spec.name, err)
+	}
+	if !common.Enabled {
+		return nil, nil
+	}
+	return &adapterInstance{
+		name:      spec.name,
+		platform:  t.Name,
+		adapter:   adapter,
+		trigger:   NewTriggerPolicy(common.Trigger),
+		owners:    toSet(common.Owners),
+		maxLength: common.MaxLength,
+		reply:     common.Reply,
+	}, nil
+}
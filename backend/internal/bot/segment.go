@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 支持的 OneBot 11 消息段类型
+const (
+	SegmentText    = "text"    // 纯文本，data.text
+	SegmentAt      = "at"      // @某人，data.qq 为 QQ 号或 all
+	SegmentReply   = "reply"   // 引用回复，data.id 为被回复的消息 ID
+	SegmentImage   = "image"   // 图片，data.file 为文件名、URL 或 base64://
+	SegmentFace    = "face"    // QQ 表情，data.id 为表情 ID
+	SegmentRecord  = "record"  // 语音，data.file
+	SegmentForward = "forward" // 合并转发，data.id 为转发 ID
+)
+
+// MessageSegment OneBot 消息段，参数值统一以字符串保存
+type MessageSegment struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+// UnmarshalJSON 解析数组格式的消息段，数字等非字符串参数转换为字符串
+func (s *MessageSegment) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type string                     `json:"type"`
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	s.Type = raw.Type
+	s.Data = make(map[string]string, len(raw.Data))
+	for k, v := range raw.Data {
+		var str string
+		if err := json.Unmarshal(v, &str); err == nil {
+			s.Data[k] = str
+		} else if string(v) != "null" {
+			s.Data[k] = string(v)
+		}
+	}
+	return nil
+}
+
+// TextSegment 构造纯文本消息段
+func TextSegment(text string) MessageSegment {
+	return MessageSegment{Type: SegmentText, Data: map[string]string{"text": text}}
+}
+
+// AtSegment 构造 @某人 的消息段
+func AtSegment(userID string) MessageSegment {
+	return MessageSegment{Type: SegmentAt, Data: map[string]string{"qq": userID}}
+}
+
+// ReplySegment 构造引用回复的消息段
+func ReplySegment(messageID string) MessageSegment {
+	return MessageSegment{Type: SegmentReply, Data: map[string]string{"id": messageID}}
+}
+
+// ImageSegment 构造图片消息段
+func ImageSegment(file string) MessageSegment {
+	return MessageSegment{Type: SegmentImage, Data: map[string]string{"file": file}}
+}
+
+// ParseSegments 解析 OneBot 上报的 message 字段，兼容字符串 (CQ 码) 与消息段数组两种格式
+func ParseSegments(raw json.RawMessage) ([]MessageSegment, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, err
+		}
+		return ParseCQ(text), nil
+	}
+	var segments []MessageSegment
+	if err := json.Unmarshal(raw, &segments); err != nil {
+		return nil, fmt.Errorf("消息段解析失败: %w", err)
+	}
+	return segments, nil
+}
+
+// ParseCQ 将含 CQ 码的字符串消息解析为消息段，格式不完整的 CQ 码按普通文本处理
+func ParseCQ(s string) []MessageSegment {
+	var segments []MessageSegment
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].Type == SegmentText {
+			segments[n-1].Data["text"] += unescapeCQ(text)
+			return
+		}
+		segments = append(segments, TextSegment(unescapeCQ(text)))
+	}
+
+	for {
+		start := strings.Index(s, "[CQ:")
+		if start < 0 {
+			appendText(s)
+			return segments
+		}
+		end := strings.IndexByte(s[start:], ']')
+		if end < 0 {
+			appendText(s)
+			return segments
+		}
+		end += start
+
+		seg, ok := parseCQCode(s[start+len("[CQ:") : end])
+		if !ok {
+			// 缺少类型的 CQ 码 (如 "[CQ:]") 按原样作为文本
+			appendText(s[:end+1])
+			s = s[end+1:]
+			continue
+		}
+		appendText(s[:start])
+		segments = append(segments, seg)
+		s = s[end+1:]
+	}
+}
+
+// parseCQCode 解析单个 CQ 码的内容 (不含 "[CQ:" 与 "]")，例如 "at,qq=123"；类型为空时返回 false
+func parseCQCode(code string) (MessageSegment, bool) {
+	parts := strings.Split(code, ",")
+	if strings.TrimSpace(parts[0]) == "" {
+		return MessageSegment{}, false
+	}
+	seg := MessageSegment{Type: parts[0], Data: make(map[string]string, len(parts)-1)}
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		seg.Data[key] = unescapeCQ(value)
+	}
+	return seg, true
+}
+
+// SerializeCQ 将消息段序列化为 CQ 码字符串，文本中的 & [ ] 与参数中的 & [ ] , 均被转义
+func SerializeCQ(segments []MessageSegment) string {
+	var b strings.Builder
+	for _, seg := range segments {
+		if seg.Type == SegmentText {
+			b.WriteString(EscapeCQText(seg.Data["text"]))
+			continue
+		}
+		b.WriteString("[CQ:")
+		b.WriteString(seg.Type)
+		for _, key := range sortedKeys(seg.Data) {
+			b.WriteString(",")
+			b.WriteString(key)
+			b.WriteString("=")
+			b.WriteString(EscapeCQParam(seg.Data[key]))
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
+
+// cqTextEscaper 与 cqParamEscaper 分别为 CQ 码文本与参数值的转义规则
+var (
+	cqTextEscaper  = strings.NewReplacer("&", "&amp;", "[", "&#91;", "]", "&#93;")
+	cqParamEscaper = strings.NewReplacer("&", "&amp;", "[", "&#91;", "]", "&#93;", ",", "&#44;")
+	cqUnescaper    = strings.NewReplacer("&#91;", "[", "&#93;", "]", "&#44;", ",", "&amp;", "&")
+)
+
+// EscapeCQText 转义纯文本，使其不会被解析为 CQ 码
+func EscapeCQText(text string) string {
+	return cqTextEscaper.Replace(text)
+}
+
+// EscapeCQParam 转义 CQ 码参数值
+func EscapeCQParam(value string) string {
+	return cqParamEscaper.Replace(value)
+}
+
+// unescapeCQ 还原 CQ 码文本或参数值中的转义字符
+func unescapeCQ(s string) string {
+	return cqUnescaper.Replace(s)
+}
+
+// sortedKeys 按字典序返回参数名，保证序列化结果稳定
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PlainText 返回消息段的纯文本投影，供 LLM 与控制台展示
+// 非文本消息段以占位符表示，引用回复不产生文本
+func PlainText(segments []MessageSegment) string {
+	var b strings.Builder
+	for _, seg := range segments {
+		switch seg.Type {
+		case SegmentText:
+			b.WriteString(seg.Data["text"])
+		case SegmentAt:
+			switch {
+			case seg.Data["qq"] == "all":
+				b.WriteString("@全体成员")
+			case seg.Data["name"] != "":
+				b.WriteString("@" + seg.Data["name"])
+			default:
+				b.WriteString("@" + seg.Data["qq"])
+			}
+		case SegmentImage:
+			b.WriteString("[图片]")
+		case SegmentFace:
+			b.WriteString("[表情]")
+		case SegmentRecord:
+			b.WriteString("[语音]")
+		case SegmentForward:
+			b.WriteString("[合并转发]")
+		case SegmentReply:
+		default:
+			b.WriteString("[" + seg.Type + "]")
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// segmentsMsgType 根据消息段推断消息类型：合并转发、语音、图片优先于文本
+func segmentsMsgType(segments []MessageSegment) MsgType {
+	msgType := MsgTypeText
+	for _, seg := range segments {
+		switch seg.Type {
+		case SegmentForward:
+			return MsgTypeForward
+		case SegmentRecord:
+			msgType = MsgTypeRecord
+		case SegmentImage:
+			if msgType == MsgTypeText {
+				msgType = MsgTypeImage
+			}
+		}
+	}
+	return msgType
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSerializeCQEscaping(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []MessageSegment
+		want     string
+	}{
+		{"文本中的 & [ ]", []MessageSegment{TextSegment("a&b [CQ:at,qq=all]")}, "a&amp;b &#91;CQ:at,qq=all&#93;"},
+		{"文本中的逗号不转义", []MessageSegment{TextSegment("1,2")}, "1,2"},
+		{"参数中的 & [ ] ,", []MessageSegment{ImageSegment("http://x/?a=1&b=[2],3")}, "[CQ:image,file=http://x/?a=1&amp;b=&#91;2&#93;&#44;3]"},
+		{"参数按名称排序", []MessageSegment{{Type: "music", Data: map[string]string{"type": "qq", "id": "1"}}}, "[CQ:music,id=1,type=qq]"},
+		{"混合消息段", []MessageSegment{ReplySegment("7"), AtSegment("42"), TextSegment(" 你好")}, "[CQ:reply,id=7][CQ:at,qq=42] 你好"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SerializeCQ(tt.segments); got != tt.want {
+				t.Errorf("SerializeCQ() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCQRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []MessageSegment
+	}{
+		{"纯文本", []MessageSegment{TextSegment("hello")}},
+		{"需转义的文本", []MessageSegment{TextSegment("&amp; [x] a,b &#91;")}},
+		{"伪造的 CQ 码文本", []MessageSegment{TextSegment("[CQ:at,qq=all]")}},
+		{"需转义的参数", []MessageSegment{ImageSegment("a&b[c],d=e")}},
+		{"多个消息段", []MessageSegment{ReplySegment("1"), AtSegment("2"), TextSegment(" [提醒] "), ImageSegment("x.png"), TextSegment("&")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serialized := SerializeCQ(tt.segments)
+			if got := ParseCQ(serialized); !reflect.DeepEqual(got, tt.segments) {
+				t.Errorf("ParseCQ(%q) = %+v, want %+v", serialized, got, tt.segments)
+			}
+		})
+	}
+}
+
+func TestParseCQMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []MessageSegment
+	}{
+		{"空字符串", "", nil},
+		{"未闭合", "hi [CQ:at,qq=1", []MessageSegment{TextSegment("hi [CQ:at,qq=1")}},
+		{"缺少类型", "a[CQ:]b", []MessageSegment{TextSegment("a[CQ:]b")}},
+		{"缺少类型与参数名", "[CQ:,qq=1]", []MessageSegment{TextSegment("[CQ:,qq=1]")}},
+		{"参数缺少等号", "[CQ:at,qq]", []MessageSegment{{Type: "at", Data: map[string]string{}}}},
+		{"参数值含等号", "[CQ:image,file=a=b]", []MessageSegment{{Type: "image", Data: map[string]string{"file": "a=b"}}}},
+		{"嵌套的左括号", "[CQ:at,qq=[CQ:face,id=1]]", []MessageSegment{
+			{Type: "at", Data: map[string]string{"qq": "[CQ:face", "id": "1"}},
+			TextSegment("]"),
+		}},
+		{"仅有右括号", "a]b[", []MessageSegment{TextSegment("a]b[")}},
+		{"未闭合的转义", "&#91 &amp", []MessageSegment{TextSegment("&#91 &amp")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseCQ(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCQ(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
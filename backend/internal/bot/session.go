@@ -0,0 +1,187 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// botSender 机器人自身消息在 model.Message 中使用的发送者名称
+const botSender = "bot"
+
+// resolveSession 查找或创建事件对应的会话，并刷新其活跃时间
+// 如果会话闲置超过配置的过期时长，则将上下文起点重置为当前时刻，开启新一轮对话
+func (m *BotManager) resolveSession(event MessageEvent) (*model.Session, error) {
+	now := time.Now()
+
+	var session model.Session
+	err := model.DB.Where(model.Session{Platform: event.Platform, PlatformID: event.PlatformID}).
+		Attrs(model.Session{ContextStart: now, LastActive: now}).
+		FirstOrCreate(&session).Error
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"last_active": now}
+	if expire := m.sessionExpire(); expire > 0 && now.Sub(session.LastActive) > expire {
+		session.ContextStart = now
+		updates["context_start"] = now
+	}
+	if name := sessionName(event, session.PlatformName); name != session.PlatformName {
+		session.PlatformName = name
+		updates["platform_name"] = name
+	}
+	session.LastActive = now
+
+	if err := model.DB.Model(&session).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// sessionName 返回会话的显示名称：私聊为对方昵称，群聊为群组名称 (改名后随之更新)；
+// 平台未提供群组名称时保留已有名称，新会话则以群组 ID 命名，不使用某位发言者的昵称
+func sessionName(event MessageEvent, current string) string {
+	switch {
+	case !event.IsGroup:
+		return event.Username
+	case event.GroupName != "":
+		return event.GroupName
+	case current != "":
+		return current
+	default:
+		return event.PlatformID
+	}
+}
+
+// findSession 根据平台及目标 ID 查找已存在的会话，不存在时返回 nil
+func (m *BotManager) findSession(platform, platformID string) *model.Session {
+	var session model.Session
+	err := model.DB.Where("platform = ? AND platform_id = ?", platform, platformID).First(&session).Error
+	if err != nil {
+		return nil
+	}
+	return &session
+}
+
+// resetSession 将会话上下文起点推进到当前时刻，之前的历史消息不再参与对话
+func (m *BotManager) resetSession(session *model.Session) error {
+	session.ContextStart = time.Now()
+	return model.DB.Model(session).Update("context_start", session.ContextStart).Error
+}
+
+// sessionExpire 解析会话闲置过期时长，配置非法或为空时视为永不过期
+func (m *BotManager) sessionExpire() time.Duration {
+	if m.chatCfg.SessionExpire == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(m.chatCfg.SessionExpire)
+	if err != nil {
+		utils.Logger.Warn("会话过期时长配置非法", zap.String("session_expire", m.chatCfg.SessionExpire))
+		return 0
+	}
+	return d
+}
+
+// buildContext 从数据库加载会话的近期历史，构造发送给 LLM 的多轮对话上下文
+// 历史按条数与字符预算双重限制，超出预算时优先丢弃最早的消息，当前消息始终保留；
+// 当前消息引用了更早的消息时，引用链优先占用预算并排在近期历史之前，即使已超出历史窗口
+// 历史只加载 ID 小于 before 的消息 (为 0 时不限)，重新生成或续写较早的回复时上下文截止于该回复
+func (m *BotManager) buildContext(session *model.Session, current *model.Message, before uint, event MessageEvent) []openai.ChatCompletionMessage {
+	var history []model.Message
+	if session != nil && m.chatCfg.ContextMessages > 0 {
+		query := model.DB.Where("session_id = ? AND created_at >= ? AND msg_type <> ?",
+			session.ID, session.ContextStart, string(MsgTypeCommand))
+		if before != 0 {
+			query = query.Where("id < ?", before)
+		}
+		if err := query.Order("created_at desc").Limit(m.chatCfg.ContextMessages).Find(&history).Error; err != nil {
+			utils.Logger.Error("加载会话历史失败", zap.Uint("session_id", session.ID), zap.Error(err))
+		}
+	}
+
+	currentMsg := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: speakerContent(event.Username, quoteHint(event.Quote)+event.Content, event.IsGroup),
+	}
+	budget := m.chatCfg.ContextChars - len([]rune(currentMsg.Content))
+
+	// 引用链从最新的消息开始累计预算，被直接引用的消息始终保留
+	inHistory := make(map[uint]bool, len(history)+1)
+	for _, h := range history {
+		inHistory[h.ID] = true
+	}
+	if current != nil {
+		inHistory[current.ID] = true
+	}
+	quoted := m.quoteContext(session, event, inHistory)
+	for i := len(quoted) - 1; i >= 0; i-- {
+		size := len([]rune(quoted[i].Content))
+		if m.chatCfg.ContextChars > 0 && size > budget && i < len(quoted)-1 {
+			quoted = quoted[i+1:]
+			break
+		}
+		budget -= size
+	}
+
+	// history 为倒序，从最新的消息开始累计字符预算
+	kept := make([]openai.ChatCompletionMessage, 0, len(history)+1)
+	for _, h := range history {
+		msg := historyMessage(h, event.IsGroup)
+		size := len([]rune(msg.Content))
+		if m.chatCfg.ContextChars > 0 && size > budget {
+			break
+		}
+		budget -= size
+		kept = append(kept, msg)
+	}
+
+	// 引用链在前，随后是时间正序的近期历史，最后追加当前消息
+	messages := make([]openai.ChatCompletionMessage, 0, len(quoted)+len(kept)+1)
+	messages = append(messages, quoted...)
+	for i := len(kept) - 1; i >= 0; i-- {
+		messages = append(messages, kept[i])
+	}
+	return append(messages, currentMsg)
+}
+
+// historyMessage 将一条持久化的消息记录转换为 LLM 对话消息
+func historyMessage(msg model.Message, isGroup bool) openai.ChatCompletionMessage {
+	if msg.Sender == botSender {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: msg.Content}
+	}
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: speakerContent(msg.Sender, msg.Content, isGroup),
+	}
+}
+
+// speakerContent 群聊中为用户消息附加发言者昵称，便于模型区分不同的说话人
+func speakerContent(name, content string, isGroup bool) string {
+	if !isGroup || name == "" {
+		return content
+	}
+	return fmt.Sprintf("%s: %s", name, content)
+}
+
+// pruneHistory 按会话历史上限删除最早的消息，避免单个会话无限增长
+func (m *BotManager) pruneHistory(sessionID uint) {
+	if m.chatCfg.HistoryCap <= 0 {
+		return
+	}
+	var cutoff model.Message
+	err := model.DB.Where("session_id = ?", sessionID).
+		Order("id desc").Offset(m.chatCfg.HistoryCap).Limit(1).
+		Find(&cutoff).Error
+	if err != nil || cutoff.ID == 0 {
+		return
+	}
+	if err := model.DB.Where("session_id = ? AND id <= ?", sessionID, cutoff.ID).Delete(&model.Message{}).Error; err != nil {
+		utils.Logger.Error("清理会话历史失败", zap.Uint("session_id", sessionID), zap.Error(err))
+	}
+}
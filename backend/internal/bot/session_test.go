@@ -0,0 +1,26 @@
+package bot
+
+import "testing"
+
+func TestSessionName(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   MessageEvent
+		current string
+		want    string
+	}{
+		{"私聊使用对方昵称", MessageEvent{PlatformID: "5", Username: "Alice"}, "", "Alice"},
+		{"私聊昵称变更", MessageEvent{PlatformID: "5", Username: "Alice2"}, "Alice", "Alice2"},
+		{"群聊使用群组名称而非发言者", MessageEvent{IsGroup: true, PlatformID: "-100", Username: "Bob", GroupName: "测试群"}, "", "测试群"},
+		{"群组改名", MessageEvent{IsGroup: true, PlatformID: "-100", Username: "Bob", GroupName: "新群名"}, "测试群", "新群名"},
+		{"无群组名称的新会话使用群组 ID", MessageEvent{IsGroup: true, PlatformID: "123456", Username: "Bob"}, "", "123456"},
+		{"无群组名称时保留已有名称", MessageEvent{IsGroup: true, PlatformID: "123456", Username: "Carol"}, "测试群", "测试群"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionName(tt.event, tt.current); got != tt.want {
+				t.Errorf("sessionName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
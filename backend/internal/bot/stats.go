@@ -0,0 +1,23 @@
+package bot
+
+import "time"
+
+// Stats 机器人运行状态快照，供管理后台仪表盘展示
+type Stats struct {
+	StartedAt  time.Time      `json:"started_at"`  // 管理器启动时间
+	Uptime     int64          `json:"uptime"`      // 已运行秒数
+	RateLimit  RateLimitStats `json:"rate_limit"`  // 限流计数器
+	QueueDepth int            `json:"queue_depth"` // 入站消息通道中等待分发的事件数
+	Pool       PoolStats      `json:"pool"`        // 回复生成工作池指标
+}
+
+// Stats 汇总管理器各组件的运行指标
+func (m *BotManager) Stats() Stats {
+	return Stats{
+		StartedAt:  m.startedAt,
+		Uptime:     int64(time.Since(m.startedAt).Seconds()),
+		RateLimit:  m.limiter.Stats(),
+		QueueDepth: len(m.msgChan),
+		Pool:       m.pool.Stats(),
+	}
+}
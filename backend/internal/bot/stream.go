@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"sk-im-bot/internal/llm"
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// partialBroadcastInterval 向管理控制台推送流式回复片段的最小间隔
+const partialBroadcastInterval = 500 * time.Millisecond
+
+// streamSeq 流式回复的自增序号，用于生成控制台侧的 StreamID
+var streamSeq atomic.Uint64
+
+// ReplyEvent 流式回复过程中推送到管理控制台的事件
+type ReplyEvent struct {
+	Type       string // 事件类型，固定为 "partial_reply"
+	StreamID   string // 同一次回复的所有片段共享此 ID，便于前端原地更新
+	Platform   string
+	PlatformID string
+	Username   string // 固定为机器人
+	Content    string // 截至当前已生成的完整文本
+	Done       bool   // 是否为最终片段
+}
+
+// streamReply 以流式方式生成回复，并通过适配器渐进投递、同步推送到管理控制台，返回已保存的回复记录
+// 未能开启流式回复时返回 false，由调用方改用一次性发送
+func (m *BotManager) streamReply(replier StreamReplier, event MessageEvent, messages []openai.ChatCompletionMessage, opts llm.ChatOptions) ([]model.Message, bool) {
+	stream, err := replier.BeginStream(event.PlatformID, event.IsGroup, m.replyLead(event))
+	if err != nil {
+		utils.Logger.Warn("开启流式回复失败，改为一次性发送", zap.String("平台", event.Platform), zap.Error(err))
+		return nil, false
+	}
+
+	streamID := fmt.Sprintf("%s-%d", event.Platform, streamSeq.Add(1))
+	var lastBroadcast time.Time
+	broadcast := func(text string, done bool) {
+		if m.broadcastFunc == nil {
+			return
+		}
+		if !done && time.Since(lastBroadcast) < partialBroadcastInterval {
+			return
+		}
+		lastBroadcast = time.Now()
+		m.broadcastFunc(ReplyEvent{
+			Type:       "partial_reply",
+			StreamID:   streamID,
+			Platform:   event.Platform,
+			PlatformID: event.PlatformID,
+			Username:   botSender,
+			Content:    text,
+			Done:       done,
+		})
+	}
+
+	response, err := m.llmClient.ChatStream(m.ctx, messages, opts, func(text string) {
+		if err := stream.Update(text); err != nil {
+			utils.Logger.Warn("流式回复投递失败", zap.String("平台", event.Platform), zap.Error(err))
+		}
+		broadcast(text, false)
+	})
+	if err != nil {
+		// 中途中断时仍投递已生成的部分内容；无内容时 Finish 仅负责释放资源 (如停止输入状态)
+		utils.Logger.Error("LLM 接口调用异常", zap.Error(err))
+		if response == "" {
+			stream.Finish("")
+			return nil, true
+		}
+	}
+
+	broadcast(response, true)
+	sent, err := stream.Finish(response)
+	var recorded []model.Message
+	for _, msg := range sent {
+		if record := m.recordReply(event, msg); record != nil {
+			recorded = append(recorded, *record)
+		}
+	}
+	if err != nil {
+		utils.Logger.Error("回复发送失败", zap.String("平台", event.Platform), zap.Error(err))
+	}
+	return recorded, true
+}
@@ -0,0 +1,624 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+
+	"sk-im-bot/internal/config"
+	"sk-im-bot/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	telegramMessageLimit = 4096            // Telegram 单条文本消息的最大字符数
+	telegramAdminTTL     = 5 * time.Minute // 群管理员列表的缓存时长
+	telegramRetryDelay   = 5 * time.Second // 请求失败后的重试间隔
+)
+
+func init() {
+	RegisterAdapterType(AdapterType{Name: "telegram", Section: "telegram", Factory: newTelegramAdapter})
+}
+
+// newTelegramAdapter 解析 Telegram 实例配置并创建适配器
+func newTelegramAdapter(name string, settings map[string]interface{}, handler func(MessageEvent) error) (BotAdapter, config.AdapterConfig, error) {
+	cfg := config.TelegramConfig{
+		AdapterConfig: config.AdapterConfig{
+			Trigger:   config.TriggerConfig{Mention: true},
+			MaxLength: telegramMessageLimit,
+		},
+		APIURL:      "https://api.telegram.org",
+		Mode:        "polling",
+		PollTimeout: 30,
+	}
+	if err := config.DecodeSection(settings, &cfg); err != nil {
+		return nil, cfg.AdapterConfig, err
+	}
+	if cfg.Mode != "polling" && cfg.Mode != "webhook" {
+		return nil, cfg.AdapterConfig, fmt.Errorf("不支持的 Telegram 接收模式 %q (可选 polling, webhook)", cfg.Mode)
+	}
+	return NewTelegramBot(name, cfg, handler), cfg.AdapterConfig, nil
+}
+
+// TelegramBot 适配器，通过 Bot API 的长轮询 (getUpdates) 或 Webhook 接收消息
+type TelegramBot struct {
+	name    string                   // 适配器实例名
+	cfg     config.TelegramConfig    // Telegram 配置 (Token、接收模式等)
+	handler func(MessageEvent) error // 消息接收回调处理逻辑
+	client  *http.Client             // Bot API 请求客户端
+	self    tgUser                   // 机器人自身信息 (getMe)，用于识别 @ 与回复
+	ready   atomic.Bool              // 已获取自身信息，可以开始处理更新
+	ctx     context.Context          // Stop 时取消，中止轮询与进行中的请求
+	cancel  context.CancelFunc
+
+	adminMu sync.Mutex
+	admins  map[string]tgAdminCache // 按聊天 ID 缓存的管理员列表
+}
+
+// tgAdminCache 单个群组的管理员缓存
+type tgAdminCache struct {
+	users   map[int64]bool
+	fetched time.Time
+}
+
+// NewTelegramBot 构造一个新的 Telegram 机器人适配器
+func NewTelegramBot(name string, cfg config.TelegramConfig, handler func(MessageEvent) error) *TelegramBot {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TelegramBot{
+		name:    name,
+		cfg:     cfg,
+		handler: handler,
+		// 长轮询请求会在服务端挂起 PollTimeout 秒，客户端超时需留出余量
+		client: &http.Client{Timeout: time.Duration(cfg.PollTimeout)*time.Second + 15*time.Second},
+		ctx:    ctx,
+		cancel: cancel,
+		admins: make(map[string]tgAdminCache),
+	}
+}
+
+// Start 获取机器人身份后按配置进入长轮询，或注册 Webhook 等待推送
+func (t *TelegramBot) Start() error {
+	utils.Logger.Info("正在连接 Telegram Bot API...", zap.String("实例", t.name), zap.String("模式", t.cfg.Mode))
+
+	// 获取自身信息，失败时持续重试直到 Stop 被调用
+	for {
+		err := t.call(t.ctx, "getMe", struct{}{}, &t.self)
+		if err == nil {
+			break
+		}
+		utils.Logger.Error("Telegram 身份获取失败，5秒后重试...", zap.Error(err))
+		if !t.sleep(telegramRetryDelay) {
+			return nil
+		}
+	}
+	t.ready.Store(true)
+
+	if t.cfg.Mode == "webhook" {
+		if t.cfg.WebhookURL != "" {
+			params := map[string]interface{}{"url": t.cfg.WebhookURL, "allowed_updates": []string{"message"}}
+			if t.cfg.WebhookSecret != "" {
+				params["secret_token"] = t.cfg.WebhookSecret
+			}
+			if err := t.call(t.ctx, "setWebhook", params, nil); err != nil {
+				utils.Logger.Error("Telegram Webhook 注册失败", zap.Error(err))
+				return err
+			}
+		}
+		utils.Logger.Info("Telegram 机器人已上线，等待 Webhook 推送", zap.String("用户名", t.self.Username), zap.String("路径", t.webhookPath()))
+		return nil
+	}
+
+	// 存在 Webhook 时 getUpdates 会被拒绝，轮询前先移除
+	if err := t.call(t.ctx, "deleteWebhook", struct{}{}, nil); err != nil {
+		utils.Logger.Warn("Telegram Webhook 移除失败", zap.Error(err))
+	}
+	utils.Logger.Info("Telegram 机器人已上线并处于长轮询状态", zap.String("用户名", t.self.Username))
+	t.poll()
+	return nil
+}
+
+// poll 长轮询循环，按 update_id 顺序处理更新，直到 Stop 被调用
+func (t *TelegramBot) poll() {
+	var offset int64
+	for t.ctx.Err() == nil {
+		var updates []tgUpdate
+		params := map[string]interface{}{
+			"offset":          offset,
+			"timeout":         t.cfg.PollTimeout,
+			"allowed_updates": []string{"message"},
+		}
+		if err := t.call(t.ctx, "getUpdates", params, &updates); err != nil {
+			if t.ctx.Err() != nil {
+				return
+			}
+			utils.Logger.Error("Telegram 拉取更新失败，5秒后重试...", zap.Error(err))
+			t.sleep(telegramRetryDelay)
+			continue
+		}
+		var err error
+		if offset, err = t.deliverUpdates(updates, offset); err != nil {
+			utils.Logger.Warn("Telegram 更新未被接收，稍后重新拉取", zap.String("实例", t.name), zap.Error(err))
+			t.sleep(telegramRetryDelay)
+		}
+	}
+}
+
+// deliverUpdates 按顺序处理一批更新并返回下次拉取的偏移；某条更新被拒绝 (如停机中) 时停在该条，
+// 其后的更新不再处理，留给 Telegram 在下次拉取时重新下发
+func (t *TelegramBot) deliverUpdates(updates []tgUpdate, offset int64) (int64, error) {
+	for _, update := range updates {
+		if err := t.handleUpdate(update); err != nil {
+			return offset, err
+		}
+		offset = update.UpdateID + 1
+	}
+	return offset, nil
+}
+
+// sleep 等待指定时长，期间 Stop 被调用时返回 false
+func (t *TelegramBot) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// Stop 停止轮询并中止进行中的请求；Webhook 保持注册，重启后可继续接收积压的更新
+func (t *TelegramBot) Stop() {
+	t.cancel()
+}
+
+// Endpoints 在 webhook 模式下提供接收推送的 HTTP 处理器
+func (t *TelegramBot) Endpoints() map[string]http.Handler {
+	if t.cfg.Mode != "webhook" {
+		return nil
+	}
+	return map[string]http.Handler{t.webhookPath(): http.HandlerFunc(t.serveWebhook)}
+}
+
+// webhookPath 返回 Webhook 在本服务上挂载的路径
+func (t *TelegramBot) webhookPath() string {
+	if t.cfg.WebhookPath != "" {
+		return t.cfg.WebhookPath
+	}
+	return "/webhook/telegram/" + t.name
+}
+
+// serveWebhook 校验密钥后处理 Telegram 推送的单条更新
+func (t *TelegramBot) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if t.cfg.WebhookSecret != "" {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.cfg.WebhookSecret)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	// 尚未获取自身信息时无法判断 @，返回 503 让 Telegram 稍后重试
+	if !t.ready.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var update tgUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// 被拒绝的更新 (如停机中) 以 503 应答，由 Telegram 稍后重新推送
+	if err := t.handleUpdate(update); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUpdate 将 Telegram 消息转换为统一的 MessageEvent 并投递给回调，返回回调拒绝事件时的错误
+func (t *TelegramBot) handleUpdate(update tgUpdate) error {
+	msg := update.Message
+	if msg == nil || msg.From == nil || msg.From.ID == t.self.ID {
+		return nil
+	}
+
+	// private 为私聊，group/supergroup 为群组，频道消息不处理
+	var isGroup bool
+	switch msg.Chat.Type {
+	case "private":
+	case "group", "supergroup":
+		isGroup = true
+	default:
+		return nil
+	}
+
+	msgType := MsgTypeText
+	text, entities := msg.Text, msg.Entities
+	if len(msg.Photo) > 0 {
+		msgType = MsgTypeImage
+		text, entities = msg.Caption, msg.CaptionEntities
+	}
+
+	content, mentioned := t.stripMentions(text, entities)
+	// 回复机器人的消息等同于 @ 机器人
+	if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.ID == t.self.ID {
+		mentioned = true
+	}
+	if content == "" {
+		if msgType != MsgTypeImage {
+			return nil // 贴纸、文件等暂不支持的消息
+		}
+		content = "[图片]"
+	}
+
+	// 引用回复：Telegram 随消息下发被回复的原消息
+	var quote *QuotedMessage
+	if ref := msg.ReplyToMessage; ref != nil {
+		quote = &QuotedMessage{ID: strconv.FormatInt(ref.MessageID, 10), Content: ref.Text}
+		if quote.Content == "" {
+			quote.Content = ref.Caption
+		}
+		if ref.From != nil {
+			quote.UserID = strconv.FormatInt(ref.From.ID, 10)
+			quote.Username = ref.From.displayName()
+			quote.FromBot = ref.From.ID == t.self.ID
+		}
+	}
+
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+	return t.handler(MessageEvent{
+		Platform:    "telegram",
+		Adapter:     t.name,
+		PlatformID:  chatID,
+		MessageID:   strconv.FormatInt(msg.MessageID, 10),
+		UserID:      strconv.FormatInt(msg.From.ID, 10),
+		Username:    msg.From.displayName(),
+		Content:     content,
+		MsgType:     msgType,
+		IsGroup:     isGroup,
+		IsMentioned: mentioned,
+		IsAdmin:     isGroup && t.isChatAdmin(chatID, msg.From.ID),
+		Quote:       quote,
+		GroupName:   msg.Chat.Title,
+	})
+}
+
+// stripMentions 识别消息中对机器人的 @ (含 /cmd@bot 形式的指令)，并从正文中移除
+// Telegram 实体的偏移量以 UTF-16 码元计算
+func (t *TelegramBot) stripMentions(text string, entities []tgEntity) (string, bool) {
+	if len(entities) == 0 || t.self.Username == "" {
+		return strings.TrimSpace(text), false
+	}
+
+	units := utf16.Encode([]rune(text))
+	handle := "@" + strings.ToLower(t.self.Username)
+	remove := make([]bool, len(units))
+	mentioned := false
+	for _, e := range entities {
+		if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(units) {
+			continue
+		}
+		part := strings.ToLower(string(utf16.Decode(units[e.Offset : e.Offset+e.Length])))
+		start, end := e.Offset, e.Offset+e.Length
+		switch {
+		case e.Type == "mention" && part == handle:
+		case e.Type == "text_mention" && e.User != nil && e.User.ID == t.self.ID:
+		case e.Type == "bot_command" && strings.HasSuffix(part, handle):
+			// 仅移除指令后缀，保留 /cmd 供指令系统解析
+			start = end - len(utf16.Encode([]rune(handle)))
+		default:
+			continue
+		}
+		mentioned = true
+		for i := start; i < end; i++ {
+			remove[i] = true
+		}
+	}
+	if !mentioned {
+		return strings.TrimSpace(text), false
+	}
+
+	kept := make([]uint16, 0, len(units))
+	for i, u := range units {
+		if !remove[i] {
+			kept = append(kept, u)
+		}
+	}
+	return strings.TrimSpace(string(utf16.Decode(kept))), true
+}
+
+// isChatAdmin 判断用户是否为群组的创建者或管理员，管理员列表按 telegramAdminTTL 缓存
+func (t *TelegramBot) isChatAdmin(chatID string, userID int64) bool {
+	t.adminMu.Lock()
+	cache, ok := t.admins[chatID]
+	t.adminMu.Unlock()
+	if ok && time.Since(cache.fetched) < telegramAdminTTL {
+		return cache.users[userID]
+	}
+
+	var members []tgChatMember
+	if err := t.call(t.ctx, "getChatAdministrators", map[string]interface{}{"chat_id": chatID}, &members); err != nil {
+		utils.Logger.Warn("Telegram 群管理员查询失败", zap.String("群组", chatID), zap.Error(err))
+		return false
+	}
+	cache = tgAdminCache{users: make(map[int64]bool, len(members)), fetched: time.Now()}
+	for _, member := range members {
+		cache.users[member.User.ID] = true
+	}
+	t.adminMu.Lock()
+	t.admins[chatID] = cache
+	t.adminMu.Unlock()
+	return cache.users[userID]
+}
+
+// SendMessage 发送一段文本消息到指定的 Telegram 聊天，返回消息 ID
+func (t *TelegramBot) SendMessage(targetID string, content string, isGroup bool) (string, error) {
+	var msg tgMessage
+	if err := t.call(t.ctx, "sendMessage", map[string]interface{}{"chat_id": targetID, "text": content}, &msg); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(msg.MessageID, 10), nil
+}
+
+// SendImage 通过图片 URL 发送图片到指定的 Telegram 聊天
+func (t *TelegramBot) SendImage(targetID string, imageUrl string, isGroup bool) error {
+	return t.call(t.ctx, "sendPhoto", map[string]interface{}{"chat_id": targetID, "photo": imageUrl}, nil)
+}
+
+// SendRich 将富消息渲染为 Telegram 消息发送：文本与 @ 合并为一条文本消息 (按用户 ID 的 @ 使用 text_mention)，
+// 图片与文件随后逐条以 sendPhoto / sendDocument 发送，引用回复作用于第一条消息；返回第一条消息的 ID
+func (t *TelegramBot) SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error) {
+	var text strings.Builder
+	var entities []tgEntity
+	var media []OutboundSegment
+	offset := 0 // 当前文本的 UTF-16 长度
+	write := func(s string) {
+		text.WriteString(s)
+		offset += len(utf16.Encode([]rune(s)))
+	}
+	for _, seg := range msg.Segments {
+		switch seg.Type {
+		case OutboundText:
+			write(seg.Text)
+		case OutboundMention:
+			id, err := strconv.ParseInt(seg.UserID, 10, 64)
+			if err != nil || seg.UserID == MentionAll {
+				// 用户名可由 Telegram 自动识别为 @；不支持 @全体成员，仅展示文本
+				write("@" + strings.TrimPrefix(seg.mentionName(), "@"))
+				continue
+			}
+			label := "@" + seg.mentionName()
+			entities = append(entities, tgEntity{
+				Type:   "text_mention",
+				Offset: offset,
+				Length: len(utf16.Encode([]rune(label))),
+				User:   &tgUser{ID: id},
+			})
+			write(label)
+		case OutboundImage, OutboundFile:
+			media = append(media, seg)
+		case OutboundEmbed:
+			write(seg.Embed.Text())
+		}
+	}
+
+	replyTo := msg.ReplyTarget()
+	firstID := ""
+	record := func(m tgMessage) {
+		if firstID == "" {
+			firstID = strconv.FormatInt(m.MessageID, 10)
+			replyTo = "" // 只有第一条消息引用回复
+		}
+	}
+
+	if text.Len() > 0 {
+		params := map[string]interface{}{"chat_id": targetID, "text": text.String()}
+		if len(entities) > 0 {
+			params["entities"] = entities
+		}
+		if replyTo != "" {
+			params["reply_parameters"] = tgReplyParameters(replyTo)
+		}
+		var sent tgMessage
+		if err := t.call(t.ctx, "sendMessage", params, &sent); err != nil {
+			return "", err
+		}
+		record(sent)
+	}
+
+	for _, seg := range media {
+		method, field := "sendDocument", "document"
+		if seg.Type == OutboundImage {
+			method, field = "sendPhoto", "photo"
+		}
+		fields := map[string]interface{}{"chat_id": targetID}
+		if replyTo != "" {
+			fields["reply_parameters"] = tgReplyParameters(replyTo)
+		}
+		var sent tgMessage
+		var err error
+		if len(seg.Data) > 0 {
+			err = t.callMultipart(t.ctx, method, fields, field, seg.fileName(), seg.Data, &sent)
+		} else {
+			fields[field] = seg.URL
+			err = t.call(t.ctx, method, fields, &sent)
+		}
+		if err != nil {
+			return firstID, err
+		}
+		record(sent)
+	}
+	return firstID, nil
+}
+
+// tgReplyParameters 构造引用回复参数，被引用的消息已删除时仍正常发送
+func tgReplyParameters(messageID string) map[string]interface{} {
+	return map[string]interface{}{
+		"message_id":                  idParam(messageID),
+		"allow_sending_without_reply": true,
+	}
+}
+
+// call 以 JSON 调用 Bot API 方法，并将 result 字段解析到 out (可为 nil)
+func (t *TelegramBot) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint(method), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s 请求构造失败", method)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return t.do(req, method, out)
+}
+
+// callMultipart 以 multipart/form-data 调用 Bot API 方法并上传一个文件，非字符串参数按 JSON 编码
+func (t *TelegramBot) callMultipart(ctx context.Context, method string, fields map[string]interface{}, fileField, fileName string, data []byte, out interface{}) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, value := range fields {
+		str, ok := value.(string)
+		if !ok {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			str = string(encoded)
+		}
+		if err := form.WriteField(key, str); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile(fileField, fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint(method), &body)
+	if err != nil {
+		return fmt.Errorf("telegram %s 请求构造失败", method)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return t.do(req, method, out)
+}
+
+// endpoint 返回 Bot API 方法的请求地址 (含 Token，不可写入日志或错误信息)
+func (t *TelegramBot) endpoint(method string) string {
+	return strings.TrimRight(t.cfg.APIURL, "/") + "/bot" + t.cfg.Token + "/" + method
+}
+
+// do 发送 Bot API 请求，并将 result 字段解析到 out (可为 nil)
+func (t *TelegramBot) do(req *http.Request, method string, out interface{}) error {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// 请求地址中含有 Token，错误信息只保留底层原因
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s 请求失败: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram %s 响应解析失败 (HTTP %d): %w", method, resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram %s 调用失败 (%d): %s", method, result.ErrorCode, result.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(result.Result, out)
+	}
+	return nil
+}
+
+// tgUpdate Bot API 推送的更新 (仅处理新消息)
+type tgUpdate struct {
+	UpdateID int64      `json:"update_id"`
+	Message  *tgMessage `json:"message"`
+}
+
+// tgMessage Bot API 消息对象中使用到的字段
+type tgMessage struct {
+	MessageID       int64         `json:"message_id"`
+	From            *tgUser       `json:"from"`
+	Chat            tgChat        `json:"chat"`
+	Text            string        `json:"text"`
+	Entities        []tgEntity    `json:"entities"`
+	Caption         string        `json:"caption"`
+	CaptionEntities []tgEntity    `json:"caption_entities"`
+	Photo           []tgPhotoSize `json:"photo"`
+	ReplyToMessage  *tgMessage    `json:"reply_to_message"`
+}
+
+// tgUser Telegram 用户或机器人
+type tgUser struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+}
+
+// displayName 返回用户的显示名称，优先使用姓名
+func (u *tgUser) displayName() string {
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	return u.Username
+}
+
+// tgChat 消息所在的聊天
+type tgChat struct {
+	ID    int64  `json:"id"`
+	Type  string `json:"type"` // private, group, supergroup, channel
+	Title string `json:"title"`
+}
+
+// tgEntity 消息中的特殊文本片段 (@、指令、链接等)
+type tgEntity struct {
+	Type   string  `json:"type"`
+	Offset int     `json:"offset"`         // UTF-16 码元偏移
+	Length int     `json:"length"`         // UTF-16 码元长度
+	User   *tgUser `json:"user,omitempty"` // text_mention 指向的用户
+}
+
+// tgPhotoSize 图片的一个尺寸版本
+type tgPhotoSize struct {
+	FileID string `json:"file_id"`
+}
+
+// tgChatMember 群成员信息 (getChatAdministrators)
+type tgChatMember struct {
+	Status string `json:"status"`
+	User   tgUser `json:"user"`
+}
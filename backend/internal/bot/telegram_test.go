@@ -0,0 +1,269 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sk-im-bot/internal/config"
+)
+
+const telegramTestToken = "123:test"
+
+// telegramCall 模拟 Bot API 收到的一次方法调用
+type telegramCall struct {
+	method string
+	params map[string]interface{}
+}
+
+// fakeBotAPI 模拟 Telegram Bot API：记录各次调用，按方法返回 results 中的结果 (未配置的方法返回 true)
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	calls   []telegramCall
+	results map[string]func(params map[string]interface{}) interface{}
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/bot"+telegramTestToken+"/")
+	if method == r.URL.Path {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+	var params map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&params)
+	f.mu.Lock()
+	f.calls = append(f.calls, telegramCall{method: method, params: params})
+	handle := f.results[method]
+	f.mu.Unlock()
+
+	var result interface{} = true
+	if handle != nil {
+		result = handle(params)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// called 返回指定方法的全部调用
+func (f *fakeBotAPI) called(method string) []telegramCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []telegramCall
+	for _, c := range f.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// newTelegramTestBot 构造指向 fakeBotAPI 的 Telegram 实例，机器人自身为 @SkBot (ID 99)
+func newTelegramTestBot(t *testing.T, mode string, handler func(MessageEvent) error) (*TelegramBot, *fakeBotAPI) {
+	t.Helper()
+	observeLogs(t)
+	api := &fakeBotAPI{results: map[string]func(map[string]interface{}) interface{}{
+		"getMe": func(map[string]interface{}) interface{} {
+			return tgUser{ID: 99, IsBot: true, FirstName: "SK", Username: "SkBot"}
+		},
+		"getChatAdministrators": func(map[string]interface{}) interface{} {
+			return []tgChatMember{{Status: "creator", User: tgUser{ID: 7}}}
+		},
+	}}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	bot := NewTelegramBot("tg", config.TelegramConfig{
+		Token:         telegramTestToken,
+		APIURL:        server.URL,
+		Mode:          mode,
+		PollTimeout:   1,
+		WebhookURL:    "https://bot.example.com/hook",
+		WebhookSecret: "secret",
+	}, handler)
+	t.Cleanup(bot.Stop)
+	return bot, api
+}
+
+func TestTelegramPolling(t *testing.T) {
+	var events []MessageEvent
+	bot, api := newTelegramTestBot(t, "polling", func(e MessageEvent) error { events = append(events, e); return nil })
+	api.results["getUpdates"] = func(params map[string]interface{}) interface{} {
+		if params["offset"].(float64) > 0 {
+			bot.Stop() // 第二次拉取：确认偏移后结束轮询
+			return []tgUpdate{}
+		}
+		return []tgUpdate{
+			{UpdateID: 10, Message: &tgMessage{MessageID: 1, From: &tgUser{ID: 5, FirstName: "Alice"}, Chat: tgChat{ID: 5, Type: "private"}, Text: "你好"}},
+			{UpdateID: 11, Message: &tgMessage{MessageID: 2, From: &tgUser{ID: 99, Username: "SkBot"}, Chat: tgChat{ID: -100, Type: "supergroup"}, Text: "机器人自己的消息"}},
+			{UpdateID: 12, Message: &tgMessage{
+				MessageID: 3,
+				From:      &tgUser{ID: 7, Username: "bob"},
+				Chat:      tgChat{ID: -100, Type: "supergroup", Title: "测试群"},
+				Text:      "@SkBot 今天天气",
+				Entities:  []tgEntity{{Type: "mention", Offset: 0, Length: 6}},
+			}},
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- bot.Start() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("轮询未在 Stop 后退出")
+	}
+
+	if len(api.called("deleteWebhook")) != 1 {
+		t.Error("轮询前未移除 Webhook")
+	}
+	polls := api.called("getUpdates")
+	if len(polls) != 2 || polls[1].params["offset"].(float64) != 13 {
+		t.Fatalf("getUpdates calls = %+v, want second call with offset 13", polls)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %d, want 2 (机器人自己的消息应被忽略)", len(events))
+	}
+	private, group := events[0], events[1]
+	if private.IsGroup || private.PlatformID != "5" || private.Content != "你好" || private.Username != "Alice" {
+		t.Errorf("private event = %+v", private)
+	}
+	if !group.IsGroup || !group.IsMentioned || !group.IsAdmin || group.Content != "今天天气" || group.PlatformID != "-100" || group.GroupName != "测试群" {
+		t.Errorf("group event = %+v", group)
+	}
+}
+
+func TestTelegramWebhook(t *testing.T) {
+	var events []MessageEvent
+	bot, api := newTelegramTestBot(t, "webhook", func(e MessageEvent) error { events = append(events, e); return nil })
+	update := `{"update_id":1,"message":{"message_id":8,"from":{"id":5,"first_name":"Alice"},"chat":{"id":5,"type":"private"},"text":"hi"}}`
+	post := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, bot.webhookPath(), strings.NewReader(update))
+		if secret != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		}
+		w := httptest.NewRecorder()
+		bot.Endpoints()[bot.webhookPath()].ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 尚未获取自身信息时让 Telegram 稍后重试
+	if code := post("secret"); code != http.StatusServiceUnavailable {
+		t.Errorf("before Start: status = %d, want 503", code)
+	}
+	if err := bot.Start(); err != nil {
+		t.Fatal(err)
+	}
+	hooks := api.called("setWebhook")
+	if len(hooks) != 1 || hooks[0].params["url"] != "https://bot.example.com/hook" || hooks[0].params["secret_token"] != "secret" {
+		t.Errorf("setWebhook calls = %+v", hooks)
+	}
+	if code := post("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: status = %d, want 401", code)
+	}
+	if code := post("secret"); code != http.StatusOK {
+		t.Errorf("status = %d, want 200", code)
+	}
+	if len(events) != 1 || events[0].Content != "hi" || events[0].MessageID != "8" {
+		t.Errorf("events = %+v", events)
+	}
+	if bot.webhookPath() != "/webhook/telegram/tg" {
+		t.Errorf("webhookPath() = %q", bot.webhookPath())
+	}
+}
+
+func TestTelegramStripMentions(t *testing.T) {
+	bot := &TelegramBot{self: tgUser{ID: 99, Username: "SkBot"}}
+	tests := []struct {
+		name      string
+		text      string
+		entities  []tgEntity
+		want      string
+		mentioned bool
+	}{
+		{"开头 @", "@SkBot 你好", []tgEntity{{Type: "mention", Offset: 0, Length: 6}}, "你好", true},
+		// 😀 占两个 UTF-16 码元，字节偏移与码元偏移不同
+		{"代理对之后的 @", "😀😀 @skbot 在吗", []tgEntity{{Type: "mention", Offset: 5, Length: 6}}, "😀😀  在吗", true},
+		{"指令后缀", "/help@SkBot", []tgEntity{{Type: "bot_command", Offset: 0, Length: 11}}, "/help", true},
+		{"text_mention", "机器人 帮我", []tgEntity{{Type: "text_mention", Offset: 0, Length: 3, User: &tgUser{ID: 99}}}, "帮我", true},
+		{"@ 其他用户", "@alice 你好", []tgEntity{{Type: "mention", Offset: 0, Length: 6}}, "@alice 你好", false},
+		{"越界实体", "@SkBot", []tgEntity{{Type: "mention", Offset: 3, Length: 6}}, "@SkBot", false},
+		{"无实体", "  你好  ", nil, "你好", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, mentioned := bot.stripMentions(tt.text, tt.entities)
+			if got != tt.want || mentioned != tt.mentioned {
+				t.Errorf("stripMentions() = (%q, %v), want (%q, %v)", got, mentioned, tt.want, tt.mentioned)
+			}
+		})
+	}
+}
+
+func TestTelegramSendMessage(t *testing.T) {
+	bot, api := newTelegramTestBot(t, "polling", func(MessageEvent) error { return nil })
+	api.results["sendMessage"] = func(map[string]interface{}) interface{} {
+		return tgMessage{MessageID: 42, Chat: tgChat{ID: -100}}
+	}
+	id, err := bot.SendMessage("-100", "你好 @all", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "42" {
+		t.Errorf("id = %q, want 42", id)
+	}
+	calls := api.called("sendMessage")
+	if len(calls) != 1 || calls[0].params["chat_id"] != "-100" || calls[0].params["text"] != "你好 @all" {
+		t.Errorf("sendMessage calls = %+v", calls)
+	}
+	if _, ok := calls[0].params["parse_mode"]; ok {
+		t.Error("纯文本消息不应指定 parse_mode")
+	}
+}
+
+func TestTelegramRefusedUpdatesAreNotAcknowledged(t *testing.T) {
+	refuse := false
+	var events []MessageEvent
+	bot, _ := newTelegramTestBot(t, "webhook", func(e MessageEvent) error {
+		if refuse {
+			return ErrShuttingDown
+		}
+		events = append(events, e)
+		return nil
+	})
+	if err := bot.Start(); err != nil {
+		t.Fatal(err)
+	}
+	update := func(id int64) tgUpdate {
+		return tgUpdate{UpdateID: id, Message: &tgMessage{MessageID: id, From: &tgUser{ID: 5}, Chat: tgChat{ID: 5, Type: "private"}, Text: "hi"}}
+	}
+
+	// 轮询：停在被拒绝的更新上，下次拉取时由 Telegram 重新下发
+	offset, err := bot.deliverUpdates([]tgUpdate{update(10), update(11)}, 0)
+	if err != nil || offset != 12 {
+		t.Fatalf("deliverUpdates() = (%d, %v), want (12, nil)", offset, err)
+	}
+	refuse = true
+	offset, err = bot.deliverUpdates([]tgUpdate{update(12), update(13)}, offset)
+	if err == nil || offset != 12 {
+		t.Errorf("refused deliverUpdates() = (%d, %v), want offset 12 and an error", offset, err)
+	}
+
+	// Webhook：以 503 应答，Telegram 稍后重新推送
+	body, _ := json.Marshal(update(14))
+	req := httptest.NewRequest(http.MethodPost, bot.webhookPath(), strings.NewReader(string(body)))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "secret")
+	w := httptest.NewRecorder()
+	bot.serveWebhook(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("refused webhook status = %d, want 503", w.Code)
+	}
+	if len(events) != 2 {
+		t.Errorf("events = %d, want 2", len(events))
+	}
+}
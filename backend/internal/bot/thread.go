@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"sk-im-bot/internal/llm"
+	"sk-im-bot/internal/model"
+	"sk-im-bot/pkg/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// threadTitleLength 子话题标题的最大字符数 (Discord 线程名上限为 100)
+const threadTitleLength = 100
+
+// threadTitleTimeout 生成子话题标题的 LLM 调用超时
+const threadTitleTimeout = 30 * time.Second
+
+// threadTitlePrompt 让 LLM 将首个问题概括为子话题标题的系统提示词
+const threadTitlePrompt = "请用不超过 20 个字概括用户的问题，作为对话标题。只输出标题本身，不要加引号或标点结尾。"
+
+// openThread 事件所在频道启用了子话题模式时，为本次对话开启子话题并改为在其中回复：
+// 子话题对应独立的会话，触发消息归入该会话，频道会话中设置的模型随之继承；开启失败时仍在原频道回复
+func (m *BotManager) openThread(event MessageEvent, session *model.Session, msg *model.Message) (MessageEvent, *model.Session) {
+	if !event.IsGroup || event.InteractionID != "" || event.ParentID != "" {
+		return event, session
+	}
+	adapter, ok := m.senderFor(event).(ThreadAdapter)
+	if !ok {
+		return event, session
+	}
+	threadID, err := adapter.OpenThread(event, threadTitle(event.Content))
+	if err != nil {
+		utils.Logger.Warn("开启对话线程失败，在原频道回复", zap.String("实例", event.Adapter), zap.Error(err))
+		return event, session
+	}
+	if threadID == "" {
+		return event, session
+	}
+
+	thread := event
+	thread.ParentID = event.PlatformID
+	thread.PlatformID = threadID
+	thread.MessageID = "" // 触发消息位于父频道，线程内改为 @提问者
+	thread.GroupName = threadTitle(event.Content)
+	threadSession, err := m.resolveSession(thread)
+	if err != nil {
+		utils.Logger.Error("线程会话解析失败", zap.Error(err))
+		return thread, nil
+	}
+	if msg != nil {
+		if err := model.DB.Model(msg).Update("session_id", threadSession.ID).Error; err != nil {
+			utils.Logger.Error("触发消息归入线程会话失败", zap.Error(err))
+		} else {
+			msg.SessionID = threadSession.ID
+		}
+	}
+	if session != nil && session.Model != "" && threadSession.Model == "" {
+		if err := model.DB.Model(threadSession).Update("model", session.Model).Error; err != nil {
+			utils.Logger.Error("线程会话继承模型失败", zap.Error(err))
+		}
+	}
+
+	utils.Logger.Info("已开启对话线程", zap.String("频道", event.PlatformID), zap.String("线程", threadID))
+	m.inflight.Add(1)
+	go func() {
+		defer m.inflight.Done()
+		m.titleThread(adapter, threadID, event.Content)
+	}()
+	return thread, threadSession
+}
+
+// titleThread 由 LLM 将首个问题概括为标题并重命名子话题，失败时保留以问题开头命名的标题
+func (m *BotManager) titleThread(adapter ThreadAdapter, threadID, question string) {
+	ctx, cancel := context.WithTimeout(m.ctx, threadTitleTimeout)
+	defer cancel()
+
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: threadTitlePrompt},
+		{Role: openai.ChatMessageRoleUser, Content: question},
+	}
+	title, err := m.llmClient.ChatWithOptions(ctx, messages, llm.ChatOptions{MaxTokens: 64})
+	if err != nil {
+		utils.Logger.Warn("生成线程标题失败", zap.String("线程", threadID), zap.Error(err))
+		return
+	}
+	title = strings.Trim(title, " \t\r\n\"'“”‘’「」《》")
+	if title == "" {
+		return
+	}
+	if err := adapter.RenameThread(threadID, threadTitle(title)); err != nil {
+		utils.Logger.Warn("线程重命名失败", zap.String("线程", threadID), zap.Error(err))
+	}
+}
+
+// threadTitle 取问题的首行作为子话题标题，超出长度上限时截断
+func threadTitle(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	title := []rune(strings.TrimSpace(line))
+	if len(title) > threadTitleLength {
+		title = append(title[:threadTitleLength-1], '…')
+	}
+	if len(title) == 0 {
+		return "新对话"
+	}
+	return string(title)
+}
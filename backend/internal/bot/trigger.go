@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"strings"
+
+	"sk-im-bot/internal/config"
+)
+
+// TriggerPolicy 根据平台配置判断一条消息是否需要机器人回复
+type TriggerPolicy struct {
+	cfg config.TriggerConfig
+}
+
+// NewTriggerPolicy 基于单个平台的触发规则配置创建判定器
+func NewTriggerPolicy(cfg config.TriggerConfig) *TriggerPolicy {
+	return &TriggerPolicy{cfg: cfg}
+}
+
+// Match 判断事件是否命中触发规则
+// 私聊始终命中；命中前缀或唤醒词时返回去除前缀后的正文，其余情况返回原始正文
+func (p *TriggerPolicy) Match(event MessageEvent) (string, bool) {
+	content := strings.TrimSpace(event.Content)
+	if !event.IsGroup {
+		return content, true
+	}
+
+	if p.cfg.Mention && event.IsMentioned {
+		return content, true
+	}
+
+	for _, prefix := range p.cfg.Prefixes {
+		if prefix != "" && strings.HasPrefix(content, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(content, prefix)), true
+		}
+	}
+
+	lower := strings.ToLower(content)
+	for _, keyword := range p.cfg.Keywords {
+		if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+			return content, true
+		}
+	}
+
+	return content, false
+}
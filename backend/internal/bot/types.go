@@ -2,6 +2,7 @@ package bot
 
 import (
 	"math/rand"
+	"net/http"
 	"time"
 )
 
@@ -11,26 +12,189 @@ type MsgType string
 const (
 	MsgTypeText  MsgType = "text"  // 普通文本
 	MsgTypeImage MsgType = "image" // 图片数据
+	// MsgTypeRecord 语音消息
+	MsgTypeRecord MsgType = "record"
+	// MsgTypeForward 合并转发消息
+	MsgTypeForward MsgType = "forward"
+	// MsgTypeFile 文件或视频附件
+	MsgTypeFile MsgType = "file"
+	// MsgTypeCommand 聊天指令，仅作记录，不计入 LLM 对话上下文
+	MsgTypeCommand MsgType = "command"
 )
 
 // MessageEvent 定义了所有机器人适配器 (QQ/Discord) 的通用消息载荷格式
 // 后端通过此结构体抹平不同平台报文的差异
 type MessageEvent struct {
-	Platform   string  // 平台标识：qq, discord
+	Platform   string  // 平台标识：qq, discord, telegram
+	Adapter    string  // 产生事件的适配器实例名，回复经由同一实例发出
+	SelfID     string  // 接收消息的机器人账号 (同一实例承载多个账号时使用，如 QQ 的 self_id)
 	PlatformID string  // 平台目标 ID (群号、频道 ID、或用户识别码)
+	MessageID  string  // 触发事件的消息在平台侧的 ID，用于引用回复
 	UserID     string  // 消息发送方的唯一 ID
 	Username   string  // 发送方显示的屏幕昵称
-	Content    string  // 消息文本正文
+	Content    string  // 消息文本正文 (富文本消息为纯文本投影，图片等以占位符表示)
 	MsgType    MsgType // 消息类型
-	IsGroup    bool    // 是否属于群组/大群环境
+	// Segments 结构化的消息段 (支持消息段的平台提供，如 QQ)，已移除对机器人的 @
+	Segments    []MessageSegment
+	IsGroup     bool // 是否属于群组/大群环境
+	IsMentioned bool // 消息中是否 @ 了机器人 (适配器已从 Content 中移除该 @)
+	IsAdmin     bool // 发送者是否为群主/群管理员 (或拥有服务器管理权限)
+	// Quote 消息引用 (回复) 的原消息，未引用时为 nil
+	Quote *QuotedMessage
+	// InteractionID 交互事件 (如 Discord 斜杠指令) 的 ID，非空时回复以该交互的响应发出，且无需满足群聊触发规则
+	InteractionID string
+	// Command 交互式指令名 (如 /reset 对应 reset)，Content 为其参数；为空表示普通对话
+	Command string
+	// Action 用户在回复控件上执行的操作，非空时事件不是聊天消息
+	Action *ReplyAction
+	// Attachments 消息携带的附件 (图片、语音、文件等)，Content 中以占位符表示
+	Attachments []Attachment
+	// ParentID 消息位于子话题 (如 Discord 线程) 中时为其父频道 ID，父频道的人设绑定对子话题同样生效
+	ParentID string
+	// GuildID 消息所在的服务器 ID (如 Discord 的 Guild)，私聊及没有服务器概念的平台为空
+	GuildID string
+	// GroupName 群聊所在群组或频道的显示名称，平台未提供时为空 (会话以群组 ID 命名)
+	GroupName string
 }
 
-// BotAdapter 平台适配器接口定义。新对接平台（如 Telegram 或微信）必须实现这些方法
+// AttachmentType 入站附件的类型
+type AttachmentType string
+
+const (
+	AttachmentImage AttachmentType = "image" // 图片
+	AttachmentAudio AttachmentType = "audio" // 语音或音频
+	AttachmentVideo AttachmentType = "video" // 视频
+	AttachmentFile  AttachmentType = "file"  // 其他文件
+)
+
+// Attachment 入站消息附件的元数据，内容需通过 URL 下载
+type Attachment struct {
+	Type        AttachmentType
+	ID          string // 附件在平台侧的 ID
+	Name        string // 文件名
+	URL         string // 下载地址
+	ContentType string // MIME 类型 (平台未提供时为空)
+	Size        int    // 文件大小 (字节)
+	Width       int    // 图片或视频的宽度 (像素)，其他类型为 0
+	Height      int    // 图片或视频的高度 (像素)，其他类型为 0
+}
+
+// placeholder 返回附件在纯文本投影中的占位符，与消息段的占位符保持一致
+func (a Attachment) placeholder() string {
+	switch a.Type {
+	case AttachmentImage:
+		return "[图片]"
+	case AttachmentAudio:
+		return "[语音]"
+	case AttachmentVideo:
+		return "[视频: " + a.Name + "]"
+	default:
+		return "[文件: " + a.Name + "]"
+	}
+}
+
+// attachmentsMsgType 根据附件推断消息类型：语音、图片优先于文件，没有附件时为 fallback
+func attachmentsMsgType(attachments []Attachment, fallback MsgType) MsgType {
+	msgType := fallback
+	for _, a := range attachments {
+		switch a.Type {
+		case AttachmentAudio:
+			return MsgTypeRecord
+		case AttachmentImage:
+			msgType = MsgTypeImage
+		default:
+			if msgType != MsgTypeImage {
+				msgType = MsgTypeFile
+			}
+		}
+	}
+	return msgType
+}
+
+// QuotedMessage 被引用 (回复) 的原消息，平台事件未携带原消息内容时 Content 为空，
+// 由实现了 QuoteResolver 的适配器在需要时补全
+type QuotedMessage struct {
+	ID       string // 原消息在平台侧的 ID
+	UserID   string // 原消息发送者 ID
+	Username string // 原消息发送者昵称
+	Content  string // 原消息的纯文本投影
+	FromBot  bool   // 原消息是否由机器人自身发出
+}
+
+// QuoteResolver 由事件中只携带被引用消息 ID 的适配器实现 (可选能力)，如 QQ 通过 get_msg 查询原消息
+type QuoteResolver interface {
+	ResolveQuote(event MessageEvent) (*QuotedMessage, error)
+}
+
+// BotAdapter 平台适配器接口定义。新对接平台（如微信）必须实现这些方法，
+// 并通过 RegisterAdapterType 注册实例工厂
 type BotAdapter interface {
-	Start() error                                                    // 启动监听任务
-	Stop()                                                           // 安全停止进程
-	SendMessage(targetID string, content string, isGroup bool) error // 执行文本回复发送
-	SendImage(targetID string, imageUrl string, isGroup bool) error  // 执行图片附件发送
+	Start() error // 启动监听任务
+	Stop()        // 安全停止进程
+	// SendMessage 执行文本回复发送，返回平台分配的消息 ID (平台不提供时为空)
+	SendMessage(targetID string, content string, isGroup bool) (string, error)
+	SendImage(targetID string, imageUrl string, isGroup bool) error // 执行图片附件发送
+	// SendRich 将平台无关的富消息渲染为平台原生格式发送，返回 (首条) 消息 ID
+	SendRich(targetID string, msg OutboundMessage, isGroup bool) (string, error)
+}
+
+// SentMessage 一条已成功发出的消息
+type SentMessage struct {
+	ID      string // 平台分配的消息 ID (平台不提供时为空)
+	Content string // 消息文本
+}
+
+// StreamReplier 由支持渐进式投递回复的适配器实现 (可选能力)
+type StreamReplier interface {
+	// BeginStream 在目标会话中开启一次流式回复，lead 为附加在首条消息上的引用回复或 @ (可为空)
+	BeginStream(targetID string, isGroup bool, lead []OutboundSegment) (ReplyStream, error)
+}
+
+// ReplyStream 一次流式回复的投递句柄，参数均为截至当前已生成的完整文本
+type ReplyStream interface {
+	Update(text string) error // 收到新的增量文本时调用，由适配器自行决定投递节奏
+	// Finish 生成结束时调用，投递全部剩余内容，并按顺序返回实际发出的各条消息
+	Finish(text string) ([]SentMessage, error)
+}
+
+// MultiAccountAdapter 由同一实例可承载多个机器人账号的适配器实现 (可选能力)
+// Account 返回绑定到指定账号的发送视图，保证回复从接收消息的账号发出
+type MultiAccountAdapter interface {
+	Account(selfID string) BotAdapter
+}
+
+// InteractionAdapter 由支持交互式指令 (如 Discord 斜杠指令) 的适配器实现 (可选能力)
+// Interaction 返回回复指定交互的发送视图，交互已过期时退回普通的频道消息
+// FinishInteraction 在交互事件处理完毕或被丢弃后调用，尚未产生任何回复时立即收起"正在思考"的占位响应
+type InteractionAdapter interface {
+	Interaction(id string) BotAdapter
+	FinishInteraction(id string)
+}
+
+// ControlsAdapter 由支持在回复上附加交互控件 (按钮、菜单) 的适配器实现 (可选能力)
+// 用户操作控件时，适配器产生携带 Action 的事件，Action.Ref 原样回传 controls.Ref
+type ControlsAdapter interface {
+	AttachControls(targetID, messageID string, controls ReplyControls) error
+}
+
+// ThreadAdapter 由支持为每次对话开启子话题 (如 Discord 线程) 的适配器实现 (可选能力)
+type ThreadAdapter interface {
+	// OpenThread 事件所在频道启用了子话题模式时，以触发消息开启子话题并返回其 ID；未启用时返回空
+	OpenThread(event MessageEvent, title string) (string, error)
+	// RenameThread 修改子话题的标题
+	RenameThread(threadID, title string) error
+}
+
+// TriggerOverrider 由支持按范围 (如 Discord 服务器) 覆盖触发规则的适配器实现 (可选能力)
+// TriggerFor 返回事件适用的触发规则，没有覆盖时返回 nil，沿用实例的触发规则
+type TriggerOverrider interface {
+	TriggerFor(event MessageEvent) *TriggerPolicy
+}
+
+// EndpointProvider 由需要通过 HTTP 接收平台推送 (Webhook 等) 的适配器实现 (可选能力)
+// 返回的处理器按路径挂载到 Web 服务器，不经过后台管理鉴权，需由适配器自行校验来源
+type EndpointProvider interface {
+	Endpoints() map[string]http.Handler
 }
 
 // RandomDelay 用于模拟真人行为。在指定范围内产生随机毫秒级的阻塞延迟
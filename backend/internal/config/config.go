@@ -6,22 +6,54 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 // Config 全局配置根结构体，映射 YAML 配置文件中的全部树状字段
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	QQ       QQConfig       `mapstructure:"qq"`
-	Discord  DiscordConfig  `mapstructure:"discord"`
-	LLM      LLMConfig      `mapstructure:"llm"`
-	Log      LogConfig      `mapstructure:"log"`
-	Admin    AdminConfig    `mapstructure:"admin"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	LLM       LLMConfig       `mapstructure:"llm"`
+	Chat      ChatConfig      `mapstructure:"chat"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Worker    WorkerConfig    `mapstructure:"worker"`
+	Log       LogConfig       `mapstructure:"log"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+
+	// Adapters 额外声明的适配器实例，可为同一平台配置多个账号
+	Adapters []AdapterInstance `mapstructure:"adapters"`
 
 	// Runtime only, loaded from llm_providers.yaml
 	LLMProviders map[string]LLMProviderConfig `mapstructure:"-"`
+
+	// Runtime only, raw settings keyed by top-level section (e.g. qq, discord)
+	sections map[string]interface{} `mapstructure:"-"`
+}
+
+// Section 返回顶层配置段的原始键值，供按名称注册配置段的模块 (如平台适配器) 自行解析
+// 配置段不存在时返回 nil
+func (c *Config) Section(name string) map[string]interface{} {
+	section, _ := c.sections[name].(map[string]interface{})
+	return section
+}
+
+// DecodeSection 将原始配置键值解析到目标结构体，规则与主配置一致
+// (弱类型转换、时长字符串、逗号分隔的列表)；out 中已有的值在缺省时保留，可用于预置默认值
+func DecodeSection(raw interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(raw)
 }
 
 // LLMProviderConfig 定义单个 LLM 提供商的连接预设
@@ -39,8 +71,9 @@ type AdminConfig struct {
 
 // ServerConfig 定义管理系统的 Web 服务选项
 type ServerConfig struct {
-	Port int    `mapstructure:"port"` // 监听端口 (默认 8888)
-	Mode string `mapstructure:"mode"` // 运行模式 (debug 或 release)
+	Port            int    `mapstructure:"port"`             // 监听端口 (默认 8888)
+	Mode            string `mapstructure:"mode"`             // 运行模式 (debug 或 release)
+	ShutdownTimeout string `mapstructure:"shutdown_timeout"` // 优雅停机的最长等待时间 (e.g. 30s)
 }
 
 // DatabaseConfig 定义 PostgreSQL 连接凭证及地址
@@ -59,18 +92,91 @@ type JWTConfig struct {
 	ExpireDuration string `mapstructure:"expire_duration"` // 授权过期周期 (例如 24h)
 }
 
+// AdapterInstance 适配器实例声明，name 与 type 之外的字段按对应平台的配置结构解析
+type AdapterInstance struct {
+	Name     string                 `mapstructure:"name"`    // 实例名，全局唯一，用于回复路由
+	Type     string                 `mapstructure:"type"`    // 适配器类型 (e.g. qq, discord, telegram)
+	Settings map[string]interface{} `mapstructure:",remain"` // 平台专属配置 (与顶层同名配置段的字段相同)
+}
+
+// AdapterConfig 各平台适配器实例共用的配置项
+type AdapterConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`    // 是否激活此实例
+	Trigger   TriggerConfig `mapstructure:"trigger"`    // 群聊回复触发规则
+	Owners    []string      `mapstructure:"owners"`     // 机器人主人用户 ID 列表，拥有全部指令权限
+	MaxLength int           `mapstructure:"max_length"` // 单条消息的最大字符数，超出后自动分段发送
+	Reply     ReplyConfig   `mapstructure:"reply"`      // 群聊回复的引用方式
+}
+
+// ReplyConfig 群聊中回复与触发消息的关联方式
+type ReplyConfig struct {
+	Mode   string            `mapstructure:"mode"`   // quote (引用触发消息，默认)、mention (@提问者)、none (直接发送)
+	Groups map[string]string `mapstructure:"groups"` // 按群号/频道 ID 覆盖 mode
+}
+
 // QQConfig QQ 接入（通常针对 go-cqhttp）的具体设置
 type QQConfig struct {
-	Enabled     bool   `mapstructure:"enabled"`      // 是否激活此模块
-	WSURL       string `mapstructure:"ws_url"`       // WebSocket 长连地址 (e.g. ws://localhost:8080)
-	AccessToken string `mapstructure:"access_token"` // OneBot 安全访问凭据 (如有)
+	AdapterConfig `mapstructure:",squash"`
+	Mode          string `mapstructure:"mode"`         // 连接模式: ws (正向 WebSocket，默认)、reverse_ws (反向 WebSocket)、http (HTTP POST 上报 + HTTP API)
+	WSURL         string `mapstructure:"ws_url"`       // ws 模式下 OneBot 的 WebSocket 地址 (e.g. ws://localhost:8080)
+	ReversePath   string `mapstructure:"reverse_path"` // reverse_ws 模式下供 OneBot 连入的路径 (默认 /onebot/<实例名>/ws)
+	HTTPURL       string `mapstructure:"http_url"`     // http 模式下 OneBot HTTP API 地址 (e.g. http://localhost:5700)
+	PostPath      string `mapstructure:"post_path"`    // http 模式下接收事件上报的路径 (默认 /onebot/<实例名>/event)
+	Secret        string `mapstructure:"secret"`       // HTTP POST 上报的签名密钥，用于校验 X-Signature (选填)
+	AccessToken   string `mapstructure:"access_token"` // OneBot 安全访问凭据 (如有)
 }
 
 // DiscordConfig Discord 服务接入参数
 type DiscordConfig struct {
-	Enabled bool   `mapstructure:"enabled"`  // 是否激活此模块
-	Token   string `mapstructure:"token"`    // 机器人应用 Token (Bot Token)
-	GuildID string `mapstructure:"guild_id"` // 限制监听的特定服务器 ID (选填)
+	AdapterConfig `mapstructure:",squash"`
+	Token         string `mapstructure:"token"`          // 机器人应用 Token (Bot Token)
+	GuildID       string `mapstructure:"guild_id"`       // 单服务器模式：只服务该服务器 (选填)，同时作为斜杠指令的注册范围
+	SlashCommands bool   `mapstructure:"slash_commands"` // 是否注册斜杠指令 (/ask /reset /persona /model)，未配置 guild_id 时全局注册
+	Components    bool   `mapstructure:"components"`     // 是否在回复上附加按钮 (重新生成、继续、评价) 与模型切换菜单
+	Embeds        bool   `mapstructure:"embeds"`         // 是否将带 Markdown 标题结构的回答渲染为嵌入卡片
+
+	Threads DiscordThreadConfig           `mapstructure:"threads"` // 对话线程模式
+	Shards  DiscordShardConfig            `mapstructure:"shards"`  // 网关分片
+	Guilds  map[string]DiscordGuildConfig `mapstructure:"guilds"`  // 按服务器 ID 的设置，非空时只服务列出的服务器 (与 guild_id 合并)
+}
+
+// DiscordShardConfig 网关分片设置，服务器数量较多时将连接拆分为多个分片
+type DiscordShardConfig struct {
+	Count int   `mapstructure:"count"` // 分片总数：1 为不分片 (默认)，0 为按 Discord 建议的数量自动分片
+	IDs   []int `mapstructure:"ids"`   // 本进程运行的分片 ID，为空时运行全部分片 (多进程部署时各进程配置不同的分片)
+}
+
+// DiscordGuildConfig 单个服务器的设置
+type DiscordGuildConfig struct {
+	Channels []string       `mapstructure:"channels"` // 允许机器人响应的频道 ID (线程按父频道判断)，为空时不限
+	Roles    []string       `mapstructure:"roles"`    // 允许使用机器人的身份组 ID，成员拥有其一即可；为空时不限
+	Trigger  *TriggerConfig `mapstructure:"trigger"`  // 覆盖实例的触发规则，未配置时沿用实例设置
+}
+
+// DiscordThreadConfig 对话线程模式：在指定频道中被触发时开启线程，在线程内继续对话 (每个线程独立会话)
+type DiscordThreadConfig struct {
+	Channels []string `mapstructure:"channels"` // 启用线程模式的频道 ID 列表，为空时关闭
+	Private  bool     `mapstructure:"private"`  // 是否开启私密线程 (仅提问者与有权限的成员可见)
+	Archive  int      `mapstructure:"archive"`  // 线程无活动后自动归档的分钟数 (Discord 支持 60、1440、4320、10080)
+}
+
+// TelegramConfig Telegram Bot API 接入参数
+type TelegramConfig struct {
+	AdapterConfig `mapstructure:",squash"`
+	Token         string `mapstructure:"token"`          // 机器人 Token (由 @BotFather 签发)
+	APIURL        string `mapstructure:"api_url"`        // Bot API 地址 (默认 https://api.telegram.org，可指向自建或测试服务)
+	Mode          string `mapstructure:"mode"`           // 接收更新的方式: polling (长轮询，默认) 或 webhook
+	PollTimeout   int    `mapstructure:"poll_timeout"`   // 长轮询单次等待的秒数
+	WebhookURL    string `mapstructure:"webhook_url"`    // webhook 模式下向 Telegram 注册的公网地址 (留空则不自动注册)
+	WebhookPath   string `mapstructure:"webhook_path"`   // webhook 在本服务上挂载的路径 (默认 /webhook/telegram/<实例名>)
+	WebhookSecret string `mapstructure:"webhook_secret"` // webhook 请求头 X-Telegram-Bot-Api-Secret-Token 的校验值 (选填)
+}
+
+// TriggerConfig 群聊中触发机器人回复的规则，任意一条命中即回复；私聊始终回复
+type TriggerConfig struct {
+	Mention  bool     `mapstructure:"mention"`  // 机器人被 @ 时回复
+	Prefixes []string `mapstructure:"prefixes"` // 消息以指定前缀或唤醒词开头时回复 (e.g. "#", "小助手")
+	Keywords []string `mapstructure:"keywords"` // 消息包含指定关键词时回复 (不区分大小写)
 }
 
 // LLMConfig 大语言模型（如 OpenAI）调用的鉴权与参数集
@@ -82,6 +188,35 @@ type LLMConfig struct {
 	MaxTokens int    `mapstructure:"max_tokens"` // 限制单次回复的最大 Token 数
 }
 
+// ChatConfig 多轮对话记忆（会话上下文）相关参数
+type ChatConfig struct {
+	ContextMessages int    `mapstructure:"context_messages"` // 每次请求携带的最大历史消息条数
+	ContextChars    int    `mapstructure:"context_chars"`    // 历史上下文的最大字符预算，超出部分从最早的消息开始丢弃
+	SessionExpire   string `mapstructure:"session_expire"`   // 会话闲置过期时长 (e.g. 30m)，过期后开启新一轮对话
+	HistoryCap      int    `mapstructure:"history_cap"`      // 单个会话在数据库中保留的最大消息条数 (0 表示不限制)
+	CommandPrefix   string `mapstructure:"command_prefix"`   // 聊天指令前缀 (默认 "/")
+	Stream          bool   `mapstructure:"stream"`           // 是否以流式方式生成并渐进投递回复
+}
+
+// RateLimitConfig LLM 回复的令牌桶限流参数，速率单位为每分钟允许的回复次数 (0 表示该维度不限制)
+type RateLimitConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`         // 是否启用限流
+	UserRate       float64 `mapstructure:"user_rate"`       // 单个用户每分钟回复次数
+	UserBurst      int     `mapstructure:"user_burst"`      // 单个用户允许的突发次数 (桶容量)
+	GroupRate      float64 `mapstructure:"group_rate"`      // 单个群组/频道每分钟回复次数
+	GroupBurst     int     `mapstructure:"group_burst"`     // 单个群组允许的突发次数
+	PlatformRate   float64 `mapstructure:"platform_rate"`   // 单个平台每分钟回复总次数
+	PlatformBurst  int     `mapstructure:"platform_burst"`  // 单个平台允许的突发次数
+	Notice         string  `mapstructure:"notice"`          // 被限流时发送的提示语 (为空则静默丢弃)
+	NoticeCooldown string  `mapstructure:"notice_cooldown"` // 同一用户两次提示之间的最小间隔 (e.g. 1m)
+}
+
+// WorkerConfig 回复生成工作池参数
+type WorkerConfig struct {
+	Workers   int `mapstructure:"workers"`    // 并发处理消息的 worker 数量 (同一会话内始终串行)
+	QueueSize int `mapstructure:"queue_size"` // 允许排队等待处理的最大消息数，超出后丢弃
+}
+
 // LogConfig 系统运行日志存储配置
 type LogConfig struct {
 	Level    string `mapstructure:"level"`    // 记录等级 (info, error, debug)
@@ -91,8 +226,84 @@ type LogConfig struct {
 // GlobalConfig 内存中持有的实时配置快照，系统各模块共享读取
 var GlobalConfig Config
 
+// setDefaults 为可选配置项注册默认值
+// 注册后的键同样可以被同名环境变量覆盖 (例如 CHAT_CONTEXT_MESSAGES)
+func setDefaults() {
+	viper.SetDefault("server.shutdown_timeout", "30s")
+
+	viper.SetDefault("chat.context_messages", 20)
+	viper.SetDefault("chat.context_chars", 6000)
+	viper.SetDefault("chat.session_expire", "30m")
+	viper.SetDefault("chat.history_cap", 500)
+	viper.SetDefault("chat.command_prefix", "/")
+	viper.SetDefault("chat.stream", true)
+
+	viper.SetDefault("worker.workers", 8)
+	viper.SetDefault("worker.queue_size", 1000)
+
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.user_rate", 6)
+	viper.SetDefault("rate_limit.user_burst", 3)
+	viper.SetDefault("rate_limit.group_rate", 20)
+	viper.SetDefault("rate_limit.group_burst", 10)
+	viper.SetDefault("rate_limit.platform_rate", 120)
+	viper.SetDefault("rate_limit.platform_burst", 30)
+	viper.SetDefault("rate_limit.notice", "你说得太快啦，请稍后再试~")
+	viper.SetDefault("rate_limit.notice_cooldown", "1m")
+
+	// 顶层 qq/discord/telegram 配置段由适配器注册表按段解析，全部键都需注册后才能被环境变量覆盖
+	viper.SetDefault("qq.enabled", false)
+	viper.SetDefault("qq.mode", "ws")
+	viper.SetDefault("qq.ws_url", "")
+	viper.SetDefault("qq.reverse_path", "")
+	viper.SetDefault("qq.http_url", "")
+	viper.SetDefault("qq.post_path", "")
+	viper.SetDefault("qq.secret", "")
+	viper.SetDefault("qq.access_token", "")
+	viper.SetDefault("qq.trigger.mention", true)
+	viper.SetDefault("qq.trigger.prefixes", []string{})
+	viper.SetDefault("qq.trigger.keywords", []string{})
+	viper.SetDefault("qq.owners", []string{})
+	viper.SetDefault("qq.max_length", 1500)
+	viper.SetDefault("qq.reply.mode", "quote")
+	viper.SetDefault("discord.enabled", false)
+	viper.SetDefault("discord.token", "")
+	viper.SetDefault("discord.guild_id", "")
+	viper.SetDefault("discord.trigger.mention", true)
+	viper.SetDefault("discord.trigger.prefixes", []string{})
+	viper.SetDefault("discord.trigger.keywords", []string{})
+	viper.SetDefault("discord.owners", []string{})
+	viper.SetDefault("discord.max_length", 2000)
+	viper.SetDefault("discord.reply.mode", "quote")
+	viper.SetDefault("discord.slash_commands", true)
+	viper.SetDefault("discord.components", true)
+	viper.SetDefault("discord.embeds", false)
+	viper.SetDefault("discord.threads.channels", []string{})
+	viper.SetDefault("discord.threads.private", false)
+	viper.SetDefault("discord.threads.archive", 1440)
+	viper.SetDefault("discord.shards.count", 1)
+	viper.SetDefault("discord.shards.ids", []int{})
+	viper.SetDefault("telegram.enabled", false)
+	viper.SetDefault("telegram.token", "")
+	viper.SetDefault("telegram.api_url", "https://api.telegram.org")
+	viper.SetDefault("telegram.mode", "polling")
+	viper.SetDefault("telegram.poll_timeout", 30)
+	viper.SetDefault("telegram.webhook_url", "")
+	viper.SetDefault("telegram.webhook_path", "")
+	viper.SetDefault("telegram.webhook_secret", "")
+	viper.SetDefault("telegram.trigger.mention", true)
+	viper.SetDefault("telegram.trigger.prefixes", []string{})
+	viper.SetDefault("telegram.trigger.keywords", []string{})
+	viper.SetDefault("telegram.owners", []string{})
+	viper.SetDefault("telegram.max_length", 4096)
+	viper.SetDefault("telegram.reply.mode", "quote")
+}
+
 // LoadConfig 使用 Viper 库加载磁盘上的 YAML 配置文件并监听环境映射
 func LoadConfig(path string) (*Config, error) {
+	// 0. 注册各可选配置项的默认值
+	setDefaults()
+
 	// 1. 设置环境变量替换规则: 将配置中的 "." 替换为 "_"
 	// 例如: Server.Port -> SERVER_PORT
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -142,6 +353,7 @@ func LoadConfig(path string) (*Config, error) {
 	if err := viper.Unmarshal(&GlobalConfig); err != nil {
 		return nil, fmt.Errorf("反序列化配置失败: %w", err)
 	}
+	GlobalConfig.sections = viper.AllSettings()
 
 	// 4. 单独加载 LLM 模型提供商配置 (backend/config/llm_providers.yaml)
 	// 我们使用一个新的 viper 实例来避免与主配置混淆，或者将其合并到 map 中
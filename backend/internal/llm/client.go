@@ -2,7 +2,11 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+
 	"sk-im-bot/internal/config"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -43,17 +47,54 @@ func NewLLMClient(cfg config.LLMConfig) *LLMClient {
 	}
 }
 
+// ChatOptions 单次对话请求的可选覆盖参数，零值字段沿用全局配置
+type ChatOptions struct {
+	Model       string  // 覆盖使用的模型版本
+	Temperature float32 // 采样温度
+	TopP        float32 // 核采样概率
+	MaxTokens   int     // 生成回复的最大 Token 数
+}
+
+// Model 返回当前配置的默认模型版本
+func (l *LLMClient) Model() string {
+	return l.config.Model
+}
+
+// Models 返回当前提供商预设中可供切换的模型列表
+func (l *LLMClient) Models() []string {
+	if preset, ok := config.GlobalConfig.LLMProviders[l.config.Provider]; ok {
+		return preset.Models
+	}
+	return nil
+}
+
 // Chat 发起一次聊天补全请求。messages 参数支持历史会话传入，从而实现多轮对话
 func (l *LLMClient) Chat(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
-	// 构造 OpenAI 规范格式的对话生成请求
-	resp, err := l.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:     l.config.Model,     // 使用配置中定义的模型版本
-			Messages:  messages,           // 对话上下文堆栈
-			MaxTokens: l.config.MaxTokens, // 生成回复的最大词数限制
-		},
-	)
+	return l.ChatWithOptions(ctx, messages, ChatOptions{})
+}
+
+// buildRequest 合并全局配置与单次覆盖参数，构造 OpenAI 规范格式的对话生成请求
+func (l *LLMClient) buildRequest(messages []openai.ChatCompletionMessage, opts ChatOptions) openai.ChatCompletionRequest {
+	model := l.config.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	maxTokens := l.config.MaxTokens
+	if opts.MaxTokens > 0 {
+		maxTokens = opts.MaxTokens
+	}
+	return openai.ChatCompletionRequest{
+		Model:       model,            // 使用配置中定义 (或会话覆盖) 的模型版本
+		Messages:    messages,         // 对话上下文堆栈
+		MaxTokens:   maxTokens,        // 生成回复的最大词数限制
+		Temperature: opts.Temperature, // 采样温度，0 时不传递由服务端决定
+		TopP:        opts.TopP,        // 核采样概率，0 时不传递由服务端决定
+	}
+}
+
+// ChatWithOptions 与 Chat 相同，但允许按会话或人设覆盖模型及采样参数
+func (l *LLMClient) ChatWithOptions(ctx context.Context, messages []openai.ChatCompletionMessage, opts ChatOptions) (string, error) {
+	resp, err := l.client.CreateChatCompletion(ctx, l.buildRequest(messages, opts))
 
 	// 网络请求或 API 层的错误处理
 	if err != nil {
@@ -68,3 +109,39 @@ func (l *LLMClient) Chat(ctx context.Context, messages []openai.ChatCompletionMe
 	// 提取生成候选集中的第一条内容作为回复
 	return resp.Choices[0].Message.Content, nil
 }
+
+// ChatStream 以流式方式发起聊天补全请求，每收到一段增量文本都会以截至当前的完整文本回调 onUpdate
+// 返回最终生成的完整回复；中途出错时返回已生成的部分文本与错误
+func (l *LLMClient) ChatStream(ctx context.Context, messages []openai.ChatCompletionMessage, opts ChatOptions, onUpdate func(text string)) (string, error) {
+	req := l.buildRequest(messages, opts)
+	req.Stream = true
+
+	stream, err := l.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("LLM API 服务器异常: %w", err)
+	}
+	defer stream.Close()
+
+	var b strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return b.String(), fmt.Errorf("LLM 流式响应中断: %w", err)
+		}
+		if len(resp.Choices) == 0 || resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+		b.WriteString(resp.Choices[0].Delta.Content)
+		if onUpdate != nil {
+			onUpdate(b.String())
+		}
+	}
+
+	if b.Len() == 0 {
+		return "", fmt.Errorf("LLM 提供商未返回有效的对话响应内容")
+	}
+	return b.String(), nil
+}
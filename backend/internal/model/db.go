@@ -30,10 +30,25 @@ func InitDB(cfg config.DatabaseConfig) {
 	// 自动迁移 (AutoMigrate)
 	// 本功能会自动对比代码结构体与表结构的差异，执行建表或字段新增操作
 	// 注意：生产环境下严禁使用该功能删除数据列
-	err = DB.AutoMigrate(&User{}, &Session{}, &Message{}, &Config{}, &Blacklist{})
+	err = DB.AutoMigrate(&User{}, &Session{}, &Message{}, &Config{}, &Blacklist{}, &Persona{}, &PersonaBinding{}, &ReplyControl{}, &Feedback{})
 	if err != nil {
 		log.Fatalf("执行数据库模型自动迁移失败: %v", err)
 	}
 
 	log.Println("数据库层初始化完成，所有数据模型同步完毕")
 }
+
+// CloseDB 关闭底层数据库连接池，应在所有写操作完成后于停机阶段调用
+func CloseDB() {
+	if DB == nil {
+		return
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		log.Printf("获取数据库连接池失败: %v", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("关闭数据库连接池失败: %v", err)
+	}
+}
@@ -24,17 +24,53 @@ type Session struct {
 	PlatformID   string    `gorm:"index" json:"platform_id"` // 平台侧的ID (群ID或用户ID)
 	PlatformName string    `json:"platform_name"`            // 平台侧显示的名称
 	LastActive   time.Time `json:"last_active"`              // 最后活跃时间
+	ContextStart time.Time `json:"context_start"`            // 对话上下文起点，早于此时间的消息不再计入记忆
+	Model        string    `json:"model"`                    // 会话级模型覆盖 (为空时使用人设或全局配置)
 }
 
 // Message 存储所有的聊天历史记录
 type Message struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`    // 消息ID
-	SessionID uint      `gorm:"index" json:"session_id"` // 所属会话ID
-	Sender    string    `json:"sender"`                  // 发送者名称 (user 或 bot)
-	Content   string    `json:"content"`                 // 消息内容文本
-	MsgType   string    `json:"msg_type"`                // 消息类型: text, image
-	RawData   string    `json:"raw_data"`                // 原始JSON数据备份
-	CreatedAt time.Time `json:"created_at"`              // 接收/发送时间
+	ID        uint   `gorm:"primaryKey" json:"id"`    // 消息ID
+	SessionID uint   `gorm:"index" json:"session_id"` // 所属会话ID
+	Sender    string `json:"sender"`                  // 发送者名称 (user 或 bot)
+	SenderID  string `json:"sender_id"`               // 发送者在平台侧的唯一 ID (机器人自身为空)
+	// PlatformMsgID 消息在平台侧的 ID (机器人回复为发送成功后平台返回的 ID)
+	PlatformMsgID string `gorm:"index" json:"platform_msg_id"`
+	// ReplyToMsgID 该消息引用的平台消息 ID (机器人回复为触发回复的消息 ID)，用于还原引用链
+	ReplyToMsgID string    `gorm:"index" json:"reply_to_msg_id"`
+	Content      string    `json:"content"`    // 消息内容文本
+	MsgType      string    `json:"msg_type"`   // 消息类型: text, image, command
+	RawData      string    `json:"raw_data"`   // 原始JSON数据备份
+	CreatedAt    time.Time `json:"created_at"` // 接收/发送时间
+}
+
+// ReplyControl 附加在机器人回复上的交互控件 (按钮、菜单) 与原始消息及会话的关联，控件 ID 中携带该记录的主键
+type ReplyControl struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`    // 主键
+	SessionID uint      `gorm:"index" json:"session_id"` // 所属会话
+	TriggerID uint      `json:"trigger_id"`              // 触发回复的用户消息
+	ReplyID   uint      `gorm:"index" json:"reply_id"`   // 附加控件的机器人回复消息 (分段回复的最后一段)
+	CreatedAt time.Time `json:"created_at"`              // 创建时间
+}
+
+// 回复评价
+const (
+	FeedbackUp   = 1  // 赞
+	FeedbackDown = -1 // 踩
+)
+
+// Feedback 用户对机器人回复的评价，同一用户对同一回复仅保留最近一次评价
+type Feedback struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`                            // 主键
+	MessageID uint      `gorm:"uniqueIndex:idx_feedback_user" json:"message_id"` // 被评价的机器人回复消息
+	SessionID uint      `gorm:"index" json:"session_id"`                         // 所属会话
+	Platform  string    `gorm:"index" json:"platform"`                           // 平台标识
+	UserID    string    `gorm:"uniqueIndex:idx_feedback_user" json:"user_id"`    // 评价者在平台侧的 ID
+	Username  string    `json:"username"`                                        // 评价者昵称
+	Rating    int       `gorm:"index" json:"rating"`                             // 评价: 1 赞, -1 踩
+	Content   string    `json:"content"`                                         // 被评价回复的内容快照 (历史清理后仍可查阅)
+	CreatedAt time.Time `json:"created_at"`                                      // 首次评价时间
+	UpdatedAt time.Time `json:"updated_at"`                                      // 最近修改时间
 }
 
 // Config 存储系统动态配置（数据库持久化版本）
@@ -44,11 +80,57 @@ type Config struct {
 	Description string `json:"description"`           // 描述信息
 }
 
-// Blacklist 存储黑名单用户信息
+// Persona 机器人人设定义，包含系统提示词与采样参数
+type Persona struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`    // 主键
+	Name         string    `gorm:"uniqueIndex" json:"name"` // 人设名称 (聊天指令中引用)
+	Description  string    `json:"description"`             // 人设简介
+	SystemPrompt string    `json:"system_prompt"`           // 系统提示词
+	Model        string    `json:"model"`                   // 使用的模型 (为空时使用全局配置)
+	Temperature  float32   `json:"temperature"`             // 采样温度 (0 表示使用服务端默认值)
+	TopP         float32   `json:"top_p"`                   // 核采样概率 (0 表示使用服务端默认值)
+	MaxTokens    int       `json:"max_tokens"`              // 最大回复 Token 数 (0 表示使用全局配置)
+	CreatedAt    time.Time `json:"created_at"`              // 创建时间
+	UpdatedAt    time.Time `json:"updated_at"`              // 更新时间
+}
+
+// 人设绑定范围，匹配优先级: 群聊/私聊 > 平台 > 全局
+const (
+	PersonaScopeGlobal   = "global"   // 全局默认人设
+	PersonaScopePlatform = "platform" // 指定平台的默认人设
+	PersonaScopeGroup    = "group"    // 指定群组/频道
+	PersonaScopePrivate  = "private"  // 指定私聊用户
+)
+
+// PersonaBinding 将人设分配到指定范围，同一范围至多绑定一个人设
+type PersonaBinding struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`                                 // 主键
+	Scope     string    `gorm:"uniqueIndex:idx_persona_binding" json:"scope"`         // 绑定范围: global, platform, group, private
+	Platform  string    `gorm:"uniqueIndex:idx_persona_binding" json:"platform"`      // 平台标识 (global 范围为空)
+	TargetID  string    `gorm:"uniqueIndex:idx_persona_binding" json:"target_id"`     // 群组 ID 或私聊用户 ID (仅 group/private 范围)
+	PersonaID uint      `gorm:"index" json:"persona_id"`                              // 绑定的人设
+	Persona   Persona   `gorm:"constraint:OnDelete:CASCADE" json:"persona,omitempty"` // 关联的人设详情
+	CreatedAt time.Time `json:"created_at"`                                           // 绑定时间
+}
+
+// 黑名单目标类型
+const (
+	BlacklistUser  = "user"  // 拉黑单个用户
+	BlacklistGroup = "group" // 拉黑整个群组/频道
+)
+
+// Blacklist 存储黑名单用户或群组信息
 type Blacklist struct {
-	ID        uint      `gorm:"primaryKey" json:"id"` // 主键
-	Platform  string    `json:"platform"`             // 限定平台
-	TargetID  string    `json:"target_id"`            // 目标用户识别码
-	Reason    string    `json:"reason"`               // 拉黑原因
-	CreatedAt time.Time `json:"created_at"`           // 拉黑时间
+	ID         uint       `gorm:"primaryKey" json:"id"`   // 主键
+	Platform   string     `gorm:"index" json:"platform"`  // 限定平台 (为空表示全部平台)
+	TargetType string     `json:"target_type"`            // 目标类型: user, group
+	TargetID   string     `gorm:"index" json:"target_id"` // 目标用户识别码或群组 ID
+	Reason     string     `json:"reason"`                 // 拉黑原因
+	ExpiresAt  *time.Time `json:"expires_at"`             // 解封时间 (为空表示永久封禁)
+	CreatedAt  time.Time  `json:"created_at"`             // 拉黑时间
+}
+
+// Active 判断该黑名单条目在指定时刻是否仍然生效
+func (b Blacklist) Active(now time.Time) bool {
+	return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
 }
@@ -8,9 +8,11 @@ import { List, Avatar, Tag } from 'antd';
  */
 const ChatConsole: React.FC = () => {
     // 从全局状态中解构需要的属性和操作方法
-    const { messages, addMessage, fetchMessages } = useStore();
+    const { messages, addMessage, upsertMessage, fetchMessages } = useStore();
     // 使用 useRef 保存 WebSocket 实例引用，防止组件刷新导致连接重建
     const ws = useRef<WebSocket | null>(null);
+    // 流式回复 StreamID 到本地消息 id 的映射，保证同一次回复只占一行
+    const streamIds = useRef<Record<string, number>>({});
 
     useEffect(() => {
         // 组件挂载时首先通过 REST 拉取一部分历史记录
@@ -29,6 +31,37 @@ const ChatConsole: React.FC = () => {
             // 后端以 MessageEvent 格式推送消息
             const msg = JSON.parse(event.data);
 
+            // 流式回复片段：按 StreamID 原地更新同一条记录
+            if (msg.Type === 'partial_reply') {
+                if (!(msg.StreamID in streamIds.current)) {
+                    streamIds.current[msg.StreamID] = Date.now();
+                }
+                upsertMessage({
+                    id: streamIds.current[msg.StreamID],
+                    sender: msg.Username,
+                    content: msg.Content,
+                    msg_type: 'text',
+                    created_at: new Date().toISOString(),
+                });
+                if (msg.Done) {
+                    delete streamIds.current[msg.StreamID];
+                }
+                return;
+            }
+
+            // 命中黑名单被丢弃的消息：单独标记展示，不混同于正常对话
+            if (msg.Type === 'blocked') {
+                addMessage({
+                    id: Date.now(),
+                    sender: msg.Username || msg.UserID || '未知用户',
+                    content: msg.Content,
+                    msg_type: msg.MsgType || 'text',
+                    created_at: new Date().toISOString(),
+                    blocked: msg.Reason || '未填写原因',
+                });
+                return;
+            }
+
             // 将接收到的原始 Socket 数据映射为前端渲染所需的 Message 格式
             const displayMsg = {
                 id: Date.now(), // 对于即时消息使用当前时间戳作为唯一键
@@ -60,7 +93,13 @@ const ChatConsole: React.FC = () => {
                     itemLayout="horizontal"
                     dataSource={messages}
                     renderItem={(item) => (
-                        <List.Item style={{ borderBottom: '1px solid rgba(255,255,255,0.03)' }}>
+                        <List.Item
+                            style={{
+                                borderBottom: '1px solid rgba(255,255,255,0.03)',
+                                // 被拦截的消息以红色边线与半透明区分
+                                ...(item.blocked !== undefined ? { borderLeft: '3px solid #ff4d4f', paddingLeft: 8, opacity: 0.6 } : {}),
+                            }}
+                        >
                             <List.Item.Meta
                                 // 使用用户名的首字母作为占位头像
                                 avatar={
@@ -80,7 +119,16 @@ const ChatConsole: React.FC = () => {
                                     <div style={{ marginTop: 4 }}>
                                         {/* 如果是图片消息，展示对应标签（此处可扩展预览图） */}
                                         {item.msg_type === 'image' && <Tag color="blue" style={{ marginBottom: 4 }}>[图片消息]</Tag>}
-                                        <div style={{ color: 'rgba(255,255,255,0.7)', wordBreak: 'break-all', fontSize: '14px' }}>
+                                        {/* 命中黑名单的消息不会得到回复，标明拦截及原因 */}
+                                        {item.blocked !== undefined && <Tag color="red" style={{ marginBottom: 4 }}>已拦截: {item.blocked}</Tag>}
+                                        <div
+                                            style={{
+                                                color: 'rgba(255,255,255,0.7)',
+                                                wordBreak: 'break-all',
+                                                fontSize: '14px',
+                                                textDecoration: item.blocked !== undefined ? 'line-through' : undefined,
+                                            }}
+                                        >
                                             {item.content}
                                         </div>
                                     </div>
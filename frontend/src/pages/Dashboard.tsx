@@ -1,7 +1,31 @@
-import React, { useEffect } from 'react';
+import React, { useEffect, useState } from 'react';
 import { useStore } from '../store/useStore';
+import api from '../api/client';
 import { Row, Col, Card, Statistic } from 'antd';
-import { MessageSquare, Users, Activity, Server } from 'lucide-react';
+import { MessageSquare, Users, Activity, Server, Gauge } from 'lucide-react';
+
+/**
+ * BotStats 对应后端 /api/stats 返回的机器人运行指标
+ */
+interface BotStats {
+    uptime: number;
+    rate_limit: {
+        enabled: boolean;
+        allowed: number;
+        limited: Record<string, number>;
+        notices_sent: number;
+        active_buckets: number;
+    };
+}
+
+/**
+ * formatUptime 将秒数格式化为易读的运行时长
+ */
+const formatUptime = (seconds: number) => {
+    const hours = Math.floor(seconds / 3600);
+    const minutes = Math.floor((seconds % 3600) / 60);
+    return hours > 0 ? `${hours}h ${minutes}m` : `${minutes}m`;
+};
 
 /**
  * Dashboard 页面：提供系统运行的核心指标可视化展示。
@@ -10,11 +34,22 @@ const Dashboard: React.FC = () => {
     // 从状态管理拉取全局消息数据
     const { messages, fetchMessages } = useStore();
 
+    // 机器人运行指标 (运行时长、限流计数)
+    const [botStats, setBotStats] = useState<BotStats | null>(null);
+
     // 初始加载拉取统计样本
     useEffect(() => {
         fetchMessages();
+        api.get<BotStats>('/stats')
+            .then((res) => setBotStats(res.data))
+            .catch((error) => console.error("无法获取运行指标:", error));
     }, []);
 
+    // 各维度限流拦截次数之和
+    const limitedTotal = botStats
+        ? Object.values(botStats.rate_limit.limited || {}).reduce((sum, n) => sum + n, 0)
+        : 0;
+
     /**
      * 系统状态卡片数据集定义
      */
@@ -31,7 +66,7 @@ const Dashboard: React.FC = () => {
         },
         {
             title: '系统稳定运行时长',
-            value: '24h',
+            value: botStats ? formatUptime(botStats.uptime) : '-',
             icon: <Activity size={24} color="#52c41a" />
         },
         {
@@ -65,7 +100,29 @@ const Dashboard: React.FC = () => {
                 ))}
             </Row>
 
-            {/* 第二行：系统动态趋势概览区 */}
+            {/* 第二行：LLM 回复限流统计 */}
+            {botStats && (
+                <Row gutter={[24, 24]} style={{ marginTop: 24 }}>
+                    <Col span={24}>
+                        <Card className="glass-card" bordered={false}>
+                            <Statistic
+                                title={
+                                    <div style={{ display: 'flex', alignItems: 'center', gap: 8 }}>
+                                        <Gauge size={24} color="#eb2f96" />
+                                        <span style={{ color: 'rgba(255,255,255,0.6)' }}>
+                                            限流拦截 / 放行 {botStats.rate_limit.enabled ? '' : '(未启用)'}
+                                        </span>
+                                    </div>
+                                }
+                                value={`${limitedTotal} / ${botStats.rate_limit.allowed}`}
+                                valueStyle={{ color: '#fff', fontWeight: '800', fontSize: '24px' }}
+                            />
+                        </Card>
+                    </Col>
+                </Row>
+            )}
+
+            {/* 第三行：系统动态趋势概览区 */}
             <Row style={{ marginTop: 24 }}>
                 <Col span={24}>
                     <Card className="glass-panel" title="最新系统行为记录" bordered={false} style={{ color: '#fff' }}>
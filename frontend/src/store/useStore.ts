@@ -10,6 +10,7 @@ interface Message {
     content: string;     // 全文字内容
     msg_type: string;    // 类型: text/image
     created_at: string;  // 生成时间
+    blocked?: string;    // 命中黑名单被拦截的消息所附的拉黑原因 (仅控制台即时推送，未落库)
 }
 
 /**
@@ -25,6 +26,7 @@ interface AppState {
     fetchMessages: () => Promise<void>; // 方法: 拉取最新消息流
     fetchConfig: () => Promise<void>;   // 方法: 同步后端配置状态
     addMessage: (msg: Message) => void; // 方法: 向本地池中插入即时消息 (通常来自 WS)
+    upsertMessage: (msg: Message) => void; // 方法: 按 id 原地更新消息，不存在时插入 (用于流式回复)
 }
 
 /**
@@ -85,4 +87,15 @@ export const useStore = create<AppState>((set) => ({
     addMessage: (msg: Message) => set((state) => ({
         messages: [msg, ...state.messages.slice(0, 99)] // 仅在控制台维护最新的 100 条记录以保证渲染性能
     })),
+
+    /**
+     * 流式回复的后续片段会携带相同的 id，此时原地替换内容而不是追加新记录
+     */
+    upsertMessage: (msg: Message) => set((state) => {
+        const exists = state.messages.some((m) => m.id === msg.id);
+        if (!exists) {
+            return { messages: [msg, ...state.messages.slice(0, 99)] };
+        }
+        return { messages: state.messages.map((m) => (m.id === msg.id ? msg : m)) };
+    }),
 }));